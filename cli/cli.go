@@ -0,0 +1,820 @@
+// File: cli/cli.go
+
+// Package cli implements the non-interactive subcommands, for logging habits
+// from scripts, cron jobs, git hooks and shell aliases. It works on the same
+// model.Store as the TUI.
+//
+// Every command returns one of the exit codes below. Query commands take
+// --format table|json|csv; see format.go for the JSON documents.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"habit-tracker/model"
+)
+
+const (
+	ExitOK       = 0
+	ExitError    = 1 // the store failed
+	ExitUsage    = 2 // bad arguments or flags
+	ExitNotFound = 3 // no habit or task matched, or the match was ambiguous
+)
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"list":    {"list [--date D] [--archived|--deleted] [--format F]", runList},
+	"done":    {"done <name|id> [--date D] [--value N]", runDone},
+	"undo":    {"undo [<name|id> [--date D]]", runUndo},
+	"redo":    {"redo", runRedo},
+	"add":     {"add <name> [--schedule S] [--goal G] [--description T] [--quit]", runAdd},
+	"archive": {"archive <name|id>", runArchive},
+	"delete":  {"delete <name|id>", runDelete},
+	"restore": {"restore <name|id>", runRestore},
+	"purge":   {"purge [--all]", runPurge},
+	"stats":   {"stats [name|id] [--format F]", runStats},
+	"log":     {"log [name|id] [--since D] [--limit N] [--format F]", runLog},
+	"task":    {"task add <name> [--due D] [--description T] | task done <name|id> | task list [--all] [--format F]", runTask},
+}
+
+// IsCommand reports whether name is one of the subcommands Run handles.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// Usage lists the subcommands, one per line.
+func Usage(w io.Writer) {
+	for _, name := range []string{"list", "done", "undo", "redo", "add", "archive", "delete", "restore", "purge", "stats", "log", "task"} {
+		fmt.Fprintf(w, "  habit %s\n", commands[name].usage)
+	}
+}
+
+type env struct {
+	store   model.Store // history, so undo and redo can walk back over changes
+	history *model.History
+	stdout  io.Writer
+}
+
+// usageError and notFoundError pick the exit code for errors that aren't
+// the store's fault.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+type notFoundError struct{ msg string }
+
+func (e notFoundError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// Run executes the subcommand named by args[0] and returns its exit code.
+func Run(store model.Store, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || !IsCommand(args[0]) {
+		Usage(stderr)
+		return ExitUsage
+	}
+	cmd := commands[args[0]]
+	history, err := model.OpenHistory(store)
+	if err == nil {
+		err = cmd.run(&env{store: history, history: history, stdout: stdout}, args[1:])
+	}
+	var usage usageError
+	var notFound notFoundError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usage):
+		if usage.msg != "" {
+			fmt.Fprintln(stderr, usage.msg)
+		}
+		fmt.Fprintf(stderr, "usage: habit %s\n", cmd.usage)
+		return ExitUsage
+	case errors.As(err, &notFound):
+		fmt.Fprintln(stderr, err)
+		return ExitNotFound
+	default:
+		fmt.Fprintf(stderr, "%s: %s\n", args[0], err)
+		return ExitError
+	}
+}
+
+// parse parses fs from args, allowing flags after positional arguments
+// (as in "habit done read --date 2024-03-01"), and returns the positionals.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, usagef("")
+			}
+			return nil, usagef("%s", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseDate accepts "2006-01-02", "today" and "yesterday", relative to the
+// store clock's day boundary.
+func (e *env) parseDate(text string) (string, error) {
+	today := e.store.Clock().Today()
+	switch text {
+	case "", "today":
+		return today.Format("2006-01-02"), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1).Format("2006-01-02"), nil
+	}
+	if _, err := time.Parse("2006-01-02", text); err != nil {
+		return "", usagef("invalid date %q: want YYYY-MM-DD, today or yesterday", text)
+	}
+	return text, nil
+}
+
+// findHabit resolves ref to an active habit by ID, or failing that by
+// case-insensitive name.
+func (e *env) findHabit(ref string) (model.Habit, error) {
+	habits, err := e.store.GetHabits()
+	if err != nil {
+		return model.Habit{}, err
+	}
+	return matchHabit(habits, ref)
+}
+
+func matchHabit(habits []model.Habit, ref string) (model.Habit, error) {
+	var byName []model.Habit
+	for _, h := range habits {
+		if h.ID == ref {
+			return h, nil
+		}
+		if strings.EqualFold(h.Name, ref) {
+			byName = append(byName, h)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return model.Habit{}, notFoundError{fmt.Sprintf("no habit %q", ref)}
+	case 1:
+		return byName[0], nil
+	default:
+		return model.Habit{}, notFoundError{fmt.Sprintf("%d habits are named %q; use the ID instead", len(byName), ref)}
+	}
+}
+
+func (e *env) findTask(ref string) (model.Task, error) {
+	tasks, err := e.store.GetTasks()
+	if err != nil {
+		return model.Task{}, err
+	}
+	var byName []model.Task
+	for _, t := range tasks {
+		if t.ID == ref {
+			return t, nil
+		}
+		if strings.EqualFold(t.Name, ref) {
+			byName = append(byName, t)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return model.Task{}, notFoundError{fmt.Sprintf("no task %q", ref)}
+	case 1:
+		return byName[0], nil
+	default:
+		return model.Task{}, notFoundError{fmt.Sprintf("%d tasks are named %q; use the ID instead", len(byName), ref)}
+	}
+}
+
+func newID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func runList(e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	dateFlag := fs.String("date", "", "show completion on this date")
+	archived := fs.Bool("archived", false, "list archived habits instead")
+	deleted := fs.Bool("deleted", false, "list deleted habits waiting to be purged instead")
+	format := formatFlag(fs)
+	if rest, err := parse(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	date, err := e.parseDate(*dateFlag)
+	if err != nil {
+		return err
+	}
+
+	if *archived && *deleted {
+		return usagef("--archived and --deleted can't be combined")
+	}
+	habits, err := e.store.GetHabits()
+	if *archived {
+		habits, err = e.store.GetArchivedHabits()
+	} else if *deleted {
+		habits, err = e.store.GetDeletedHabits()
+	}
+	if err != nil {
+		return err
+	}
+	doc := HabitList{SchemaVersion: SchemaVersion, Date: date, Habits: make([]HabitOnDate, 0, len(habits))}
+	out := output{
+		json: &doc,
+		csv:  [][]string{{"id", "name", "kind", "schedule", "goal", "status", "value"}},
+	}
+	for _, h := range habits {
+		row, err := e.onDate(h, date)
+		if err != nil {
+			return err
+		}
+		doc.Habits = append(doc.Habits, row)
+		value, progress := "", row.Status
+		if row.Value != nil {
+			value = model.FormatValue(*row.Value)
+			progress = value + "/" + model.FormatValue(h.Goal.Target)
+		}
+		out.csv = append(out.csv, []string{h.ID, h.Name, kindName(h), h.Schedule.String(), h.Goal.String(), row.Status, value})
+		out.table = append(out.table, []string{h.ID, progress, h.Name, describe(h)})
+	}
+	return e.print(*format, out)
+}
+
+// onDate works out h's state on date.
+func (e *env) onDate(h model.Habit, date string) (HabitOnDate, error) {
+	row := habitOnDate(h)
+	from, to := date, date
+	if h.Goal != nil && h.Goal.Per == "week" {
+		day, _ := time.Parse("2006-01-02", date)
+		start := e.store.Clock().StartOfWeek(day)
+		from, to = start.Format("2006-01-02"), start.AddDate(0, 0, 6).Format("2006-01-02")
+	}
+	completions, err := e.store.CompletionsBetween(h.ID, from, to)
+	if err != nil {
+		return row, err
+	}
+	done := len(completions) > 0 && completions[len(completions)-1].Date == date
+	switch {
+	case h.IsQuit() && done:
+		row.Status = "relapsed"
+	case h.IsQuit():
+		row.Status = "clean"
+	case h.Goal != nil:
+		value := h.Goal.Aggregate(completions)
+		row.Value = &value
+		switch {
+		case value >= h.Goal.Target:
+			row.Status = "done"
+		case value > 0:
+			row.Status = "partial"
+		case !h.Schedule.IsDue(date):
+			row.Status = "not-due"
+		default:
+			row.Status = "pending"
+		}
+	case done:
+		row.Status = "done"
+	case !h.Schedule.IsDue(date):
+		row.Status = "not-due"
+	default:
+		row.Status = "pending"
+	}
+	return row, nil
+}
+
+func describe(h model.Habit) string {
+	if h.IsQuit() {
+		return "quit"
+	}
+	if h.Goal != nil {
+		return h.Schedule.String() + ", " + h.Goal.String()
+	}
+	return h.Schedule.String()
+}
+
+func runDone(e *env, args []string) error {
+	fs := flag.NewFlagSet("done", flag.ContinueOnError)
+	dateFlag := fs.String("date", "", "day to log (default today)")
+	value := fs.Float64("value", 0, "amount to add, for habits with a goal")
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("done needs a habit")
+	}
+	date, err := e.parseDate(*dateFlag)
+	if err != nil {
+		return err
+	}
+	h, err := e.findHabit(strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+
+	if err := model.ValidateValue(*value); err != nil {
+		return usagef("--value: %s", err)
+	}
+	if h.Goal != nil {
+		if *value <= 0 {
+			return usagef("%s tracks %s; pass a positive --value", h.Name, h.Goal.Unit)
+		}
+		completions, err := e.store.CompletionsBetween(h.ID, date, date)
+		if err != nil {
+			return err
+		}
+		current := 0.0
+		if len(completions) > 0 {
+			current = completions[0].Value
+		}
+		total := h.Goal.Combine(current, *value)
+		if err := e.store.SetHabitValue(h.ID, date, total); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "%s on %s: %s/%s %s\n", h.Name, date, model.FormatValue(total), model.FormatValue(h.Goal.Target), h.Goal.Unit)
+		return nil
+	}
+	if *value != 0 {
+		return usagef("%s has no goal; --value doesn't apply", h.Name)
+	}
+
+	done, err := e.store.IsHabitCompleted(h.ID, date)
+	if err != nil {
+		return err
+	}
+	verb := "done"
+	if h.IsQuit() {
+		verb = "relapse logged"
+	}
+	if done {
+		fmt.Fprintf(e.stdout, "%s already %s on %s\n", h.Name, verb, date)
+		return nil
+	}
+	if err := e.store.ToggleHabitCompletion(h.ID, date); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "%s %s on %s\n", h.Name, verb, date)
+	return nil
+}
+
+// runUndo clears a habit's day, or without a habit undoes the latest
+// change.
+func runUndo(e *env, args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	dateFlag := fs.String("date", "", "day to clear (default today)")
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		if *dateFlag != "" {
+			return usagef("--date needs a habit")
+		}
+		return e.walkHistory(e.history.Undo, model.ErrNothingToUndo, "undone")
+	}
+	date, err := e.parseDate(*dateFlag)
+	if err != nil {
+		return err
+	}
+	h, err := e.findHabit(strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+	done, err := e.store.IsHabitCompleted(h.ID, date)
+	if err != nil {
+		return err
+	}
+	if !done {
+		fmt.Fprintf(e.stdout, "nothing logged for %s on %s\n", h.Name, date)
+		return nil
+	}
+	if err := e.store.ToggleHabitCompletion(h.ID, date); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "cleared %s on %s\n", h.Name, date)
+	return nil
+}
+
+func runRedo(e *env, args []string) error {
+	fs := flag.NewFlagSet("redo", flag.ContinueOnError)
+	if rest, err := parse(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	return e.walkHistory(e.history.Redo, model.ErrNothingToRedo, "redone")
+}
+
+// walkHistory undoes or redoes the latest change made from the command
+// line or the TUI. The history is kept in the store, so it spans separate
+// runs.
+func (e *env) walkHistory(walk func() (model.Operation, error), nothing error, done string) error {
+	op, err := walk()
+	if errors.Is(err, nothing) {
+		fmt.Fprintln(e.stdout, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "%s: %s\n", done, op.Summary)
+	return nil
+}
+
+func runAdd(e *env, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	scheduleFlag := fs.String("schedule", "daily", "daily, mon,wed,fri, 3/week, 2/month or every 3 days")
+	goalFlag := fs.String("goal", "", "e.g. 8 glasses/day or 20 km/week")
+	description := fs.String("description", "", "longer description")
+	quit := fs.Bool("quit", false, "a habit to quit: log relapses and count days clean")
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(strings.Join(rest, " "))
+	if name == "" {
+		return usagef("add needs a name")
+	}
+	schedule, err := model.ParseSchedule(*scheduleFlag, e.store.Clock().Today())
+	if err != nil {
+		return usagef("%s", err)
+	}
+	goal, err := model.ParseGoal(*goalFlag)
+	if err != nil {
+		return usagef("%s", err)
+	}
+	h := model.Habit{
+		ID:          newID(),
+		Name:        name,
+		Description: *description,
+		Type:        "general",
+		Notes:       make(map[string]string),
+		Schedule:    schedule,
+		Goal:        goal,
+	}
+	if *quit {
+		if goal != nil || *scheduleFlag != "daily" {
+			return usagef("quit habits are tracked daily and have no goal")
+		}
+		h.Kind = model.KindQuit
+	}
+	if err := e.store.AddHabit(h); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "added %s (%s)\n", h.Name, h.ID)
+	return nil
+}
+
+func runArchive(e *env, args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("archive needs a habit")
+	}
+	h, err := e.findHabit(strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+	if err := e.store.ArchiveHabit(h.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "archived %s\n", h.Name)
+	return nil
+}
+
+// graceDays is model.DeleteGracePeriod in days, for messages.
+var graceDays = int(model.DeleteGracePeriod.Hours() / 24)
+
+// runDelete deletes an active or archived habit. Its history stays until
+// the grace period is over and purge runs.
+func runDelete(e *env, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("delete needs a habit")
+	}
+	active, err := e.store.GetHabits()
+	if err != nil {
+		return err
+	}
+	archived, err := e.store.GetArchivedHabits()
+	if err != nil {
+		return err
+	}
+	h, err := matchHabit(append(active, archived...), strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+	completions, err := e.store.CompletionsBetween(h.ID, "", "9999-12-31")
+	if err != nil {
+		return err
+	}
+	if err := e.store.DeleteHabit(h.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "deleted %s with %s; habit restore %s brings it back for %d days\n",
+		h.Name, plural(len(completions), "completion"), h.ID, graceDays)
+	return nil
+}
+
+func runRestore(e *env, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("restore needs a habit")
+	}
+	deleted, err := e.store.GetDeletedHabits()
+	if err != nil {
+		return err
+	}
+	h, err := matchHabit(deleted, strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+	if err := e.store.RestoreHabit(h.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "restored %s\n", h.Name)
+	return nil
+}
+
+// runPurge makes deletions final once their grace period is over, or
+// straight away with --all.
+func runPurge(e *env, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	all := fs.Bool("all", false, "also purge habits still in their grace period")
+	if rest, err := parse(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	cutoff := time.Now().Add(-model.DeleteGracePeriod)
+	if *all {
+		cutoff = time.Now().Add(time.Second)
+	}
+	purged, err := e.store.PurgeDeletedHabits(cutoff)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(purged) == 0 && *all:
+		fmt.Fprintln(e.stdout, "nothing to purge")
+	case len(purged) == 0:
+		fmt.Fprintf(e.stdout, "nothing was deleted more than %d days ago\n", graceDays)
+	}
+	for _, h := range purged {
+		fmt.Fprintf(e.stdout, "purged %s\n", h.Name)
+	}
+	return nil
+}
+
+func runStats(e *env, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	format := formatFlag(fs)
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	var habits []model.Habit
+	switch len(rest) {
+	case 0:
+		if habits, err = e.store.GetHabits(); err != nil {
+			return err
+		}
+	default:
+		h, err := e.findHabit(strings.Join(rest, " "))
+		if err != nil {
+			return err
+		}
+		habits = []model.Habit{h}
+	}
+
+	doc := StatsList{SchemaVersion: SchemaVersion, Stats: make([]HabitStats, 0, len(habits))}
+	out := output{
+		json: &doc,
+		csv: [][]string{{"id", "name", "kind", "unit", "current", "longest", "longest_start", "longest_end",
+			"total", "last_relapse", "relapses_per_30_days"}},
+	}
+	for _, h := range habits {
+		st, err := e.store.GetHabitStats(h.ID)
+		if err != nil {
+			return err
+		}
+		doc.Stats = append(doc.Stats, habitStats(h, st))
+		out.csv = append(out.csv, []string{h.ID, h.Name, kindName(h), st.Unit, strconv.Itoa(st.Current), strconv.Itoa(st.Longest),
+			st.LongestStart, st.LongestEnd, strconv.Itoa(st.Total), st.LastRelapse, strconv.FormatFloat(st.RelapseRate, 'f', 2, 64)})
+		if h.IsQuit() {
+			out.table = append(out.table, []string{h.Name, "clean " + plural(st.Current, "day"), "longest clean " + plural(st.Longest, "day"),
+				fmt.Sprintf("%d relapses (%.1f per 30 days)", st.Total, st.RelapseRate)})
+			continue
+		}
+		out.table = append(out.table, []string{h.Name, "current " + plural(st.Current, st.Unit), "best " + plural(st.Longest, st.Unit),
+			fmt.Sprintf("total %d", st.Total)})
+	}
+	return e.print(*format, out)
+}
+
+// runLog prints the event log newest first, for every habit and task or
+// just one habit. A habit purged since can still be named by its ID.
+func runLog(e *env, args []string) error {
+	fs := flag.NewFlagSet("log", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "", "only events on or after this day")
+	limit := fs.Int("limit", 50, "most events to show, 0 for all")
+	format := formatFlag(fs)
+	rest, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return usagef("--limit can't be negative")
+	}
+	clock := e.store.Clock()
+	var since time.Time
+	if *sinceFlag != "" {
+		date, err := e.parseDate(*sinceFlag)
+		if err != nil {
+			return err
+		}
+		day, _ := time.Parse("2006-01-02", date)
+		since = clock.StartOfDay(day)
+	}
+
+	habitID := ""
+	if len(rest) > 0 {
+		if habitID, err = e.logHabit(strings.Join(rest, " ")); err != nil {
+			return err
+		}
+	}
+	events, err := e.store.Events(habitID, since, *limit)
+	if err != nil {
+		return err
+	}
+
+	doc := EventList{SchemaVersion: SchemaVersion, Events: []Event{}}
+	out := output{
+		json: &doc,
+		csv:  [][]string{{"seq", "at", "action", "habit_id", "task_id", "name", "date", "payload"}},
+	}
+	for _, ev := range events {
+		doc.Events = append(doc.Events, event(ev))
+		out.csv = append(out.csv, []string{strconv.FormatUint(ev.Seq, 10), ev.At, ev.Action, ev.HabitID, ev.TaskID, ev.Name, ev.Date, string(ev.Payload)})
+		at := ev.At
+		if t, err := time.Parse(time.RFC3339, ev.At); err == nil {
+			at = t.In(clock.Home()).Format("2006-01-02 15:04")
+		}
+		out.table = append(out.table, []string{at, ev.Action, ev.Name, ev.Date})
+	}
+	return e.print(*format, out)
+}
+
+// logHabit resolves ref to the ID of a habit whether it's active, archived
+// or deleted, or to the ID of one that has since been purged.
+func (e *env) logHabit(ref string) (string, error) {
+	var habits []model.Habit
+	for _, list := range []func() ([]model.Habit, error){e.store.GetHabits, e.store.GetArchivedHabits, e.store.GetDeletedHabits} {
+		some, err := list()
+		if err != nil {
+			return "", err
+		}
+		habits = append(habits, some...)
+	}
+	h, err := matchHabit(habits, ref)
+	var notFound notFoundError
+	if !errors.As(err, &notFound) {
+		return h.ID, err
+	}
+	if events, lookupErr := e.store.Events(ref, time.Time{}, 1); lookupErr != nil {
+		return "", lookupErr
+	} else if len(events) > 0 {
+		return ref, nil
+	}
+	return "", err
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func runTask(e *env, args []string) error {
+	if len(args) == 0 {
+		return usagef("task needs add, done or list")
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("task add", flag.ContinueOnError)
+		dueFlag := fs.String("due", "", "due date, YYYY-MM-DD")
+		description := fs.String("description", "", "longer description")
+		rest, err := parse(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(strings.Join(rest, " "))
+		if name == "" {
+			return usagef("task add needs a name")
+		}
+		due := ""
+		if *dueFlag != "" {
+			if due, err = e.parseDate(*dueFlag); err != nil {
+				return err
+			}
+		}
+		id := newID()
+		if err := e.store.AddTask(id, name, *description, due); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "added task %s (%s)\n", name, id)
+		return nil
+	case "done":
+		rest, err := parse(flag.NewFlagSet("task done", flag.ContinueOnError), args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) == 0 {
+			return usagef("task done needs a task")
+		}
+		t, err := e.findTask(strings.Join(rest, " "))
+		if err != nil {
+			return err
+		}
+		if t.Completed {
+			fmt.Fprintf(e.stdout, "task %s is already done\n", t.Name)
+			return nil
+		}
+		if err := e.store.ToggleTask(t.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "task %s done\n", t.Name)
+		return nil
+	case "list":
+		fs := flag.NewFlagSet("task list", flag.ContinueOnError)
+		all := fs.Bool("all", false, "include completed tasks")
+		format := formatFlag(fs)
+		if _, err := parse(fs, args[1:]); err != nil {
+			return err
+		}
+		if err := checkFormat(*format); err != nil {
+			return err
+		}
+		tasks, err := e.store.GetTasks()
+		if err != nil {
+			return err
+		}
+		doc := TaskList{SchemaVersion: SchemaVersion, Tasks: make([]Task, 0, len(tasks))}
+		out := output{
+			json: &doc,
+			csv:  [][]string{{"id", "name", "description", "due_date", "completed", "created_at"}},
+		}
+		for _, t := range tasks {
+			if t.Completed && !*all {
+				continue
+			}
+			doc.Tasks = append(doc.Tasks, task(t))
+			out.csv = append(out.csv, []string{t.ID, t.Name, t.Description, t.DueDate, strconv.FormatBool(t.Completed), t.CreatedAt})
+			mark := "[ ]"
+			if t.Completed {
+				mark = "[x]"
+			}
+			out.table = append(out.table, []string{t.ID, mark, t.Name, t.DueDate})
+		}
+		return e.print(*format, out)
+	default:
+		return usagef("unknown task command %q", args[0])
+	}
+}
@@ -0,0 +1,305 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"habit-tracker/model"
+)
+
+func run(t *testing.T, store model.Store, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(store, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAddDoneAndUndo(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+
+	if code, _, stderr := run(t, store, "add", "Read", "books", "--schedule", "mon,wed,fri"); code != ExitOK {
+		t.Fatalf("add exited %d: %s", code, stderr)
+	}
+	habits, _ := store.GetHabits()
+	if len(habits) != 1 || habits[0].Name != "Read books" || habits[0].Schedule.String() != "mon,wed,fri" {
+		t.Fatalf("unexpected habits after add: %+v", habits)
+	}
+
+	if code, out, stderr := run(t, store, "done", "read books", "--date", "2024-03-01"); code != ExitOK || !strings.Contains(out, "done on 2024-03-01") {
+		t.Fatalf("done exited %d: %s%s", code, out, stderr)
+	}
+	if done, _ := store.IsHabitCompleted(habits[0].ID, "2024-03-01"); !done {
+		t.Fatal("expected done to log the completion")
+	}
+	// Running it again must not toggle the day back off.
+	run(t, store, "done", habits[0].ID, "--date", "2024-03-01")
+	if done, _ := store.IsHabitCompleted(habits[0].ID, "2024-03-01"); !done {
+		t.Fatal("expected done to be idempotent")
+	}
+
+	if code, _, _ := run(t, store, "undo", "Read books", "--date", "2024-03-01"); code != ExitOK {
+		t.Fatalf("undo exited %d", code)
+	}
+	if done, _ := store.IsHabitCompleted(habits[0].ID, "2024-03-01"); done {
+		t.Fatal("expected undo to clear the completion")
+	}
+}
+
+func TestUndoAndRedoLatestChange(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	run(t, store, "add", "stretch")
+	run(t, store, "done", "stretch", "--date", "2024-03-01")
+	run(t, store, "archive", "stretch")
+
+	if _, out, _ := run(t, store, "undo"); out != "undone: archived \"stretch\"\n" {
+		t.Fatalf("expected undo to reverse the archive, got %q", out)
+	}
+	run(t, store, "undo")
+	habits, _ := store.GetHabits()
+	if done, _ := store.IsHabitCompleted(habits[0].ID, "2024-03-01"); done {
+		t.Fatal("expected a second undo to clear the day")
+	}
+	if _, out, _ := run(t, store, "redo"); !strings.Contains(out, "redone: toggled") {
+		t.Fatalf("expected redo to log the day again, got %q", out)
+	}
+	if done, _ := store.IsHabitCompleted(habits[0].ID, "2024-03-01"); !done {
+		t.Fatal("expected redo to log the day again")
+	}
+	run(t, store, "undo")
+	run(t, store, "undo")
+	if _, out, _ := run(t, store, "undo"); out != "nothing to undo\n" {
+		t.Fatalf("expected the history to run out after the add, got %q", out)
+	}
+	if code, _, _ := run(t, store, "undo", "--date", "2024-03-01"); code != ExitUsage {
+		t.Fatalf("expected --date without a habit to be a usage error, got %d", code)
+	}
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	run(t, store, "add", "stretch")
+	run(t, store, "add", "read")
+	run(t, store, "done", "stretch", "--date", "2024-03-01")
+	run(t, store, "undo")
+	run(t, store, "delete", "stretch")
+
+	code, out, stderr := run(t, store, "log", "stretch", "--format", "json")
+	if code != ExitOK {
+		t.Fatalf("log exited %d: %s", code, stderr)
+	}
+	var doc EventList
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	var actions []string
+	for _, e := range doc.Events {
+		actions = append(actions, e.Action)
+	}
+	want := "habit.delete completion.toggle completion.toggle habit.create"
+	if strings.Join(actions, " ") != want {
+		t.Fatalf("expected the deleted habit's events newest first (%s), got %v", want, actions)
+	}
+	if string(doc.Events[2].Payload) == "null" || string(doc.Events[1].Payload) != "null" {
+		t.Fatalf("expected the undone toggle to be logged with a null payload: %+v", doc.Events)
+	}
+
+	if _, out, _ := run(t, store, "log", "--limit", "2"); strings.Count(out, "\n") != 2 || !strings.Contains(out, "habit.delete") {
+		t.Fatalf("expected the two latest events, got:\n%s", out)
+	}
+	if code, _, _ := run(t, store, "log", "--since", "last week"); code != ExitUsage {
+		t.Fatalf("expected a bad --since to be a usage error, got %d", code)
+	}
+	if _, out, _ := run(t, store, "log", "--since", "today", "--limit", "0"); strings.Count(out, "\n") != 5 {
+		t.Fatalf("expected all five events from today, got:\n%s", out)
+	}
+	if code, _, _ := run(t, store, "log", "nope"); code != ExitNotFound {
+		t.Fatalf("expected an unknown habit to exit %d, got %d", ExitNotFound, code)
+	}
+}
+
+func TestDoneWithValue(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	run(t, store, "add", "water", "--goal", "8 glasses/day")
+
+	if code, _, _ := run(t, store, "done", "water"); code != ExitUsage {
+		t.Fatalf("expected a goal habit without --value to be a usage error, got %d", code)
+	}
+	for _, v := range []string{"NaN", "+Inf"} {
+		if code, _, _ := run(t, store, "done", "water", "--value", v); code != ExitUsage {
+			t.Fatalf("expected --value %s to be a usage error, got %d", v, code)
+		}
+	}
+	run(t, store, "done", "water", "--value", "3", "--date", "2024-03-01")
+	code, out, _ := run(t, store, "done", "water", "--value", "5", "--date", "2024-03-01")
+	if code != ExitOK || !strings.Contains(out, "8/8 glasses") {
+		t.Fatalf("expected values to add up, got %d: %s", code, out)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	store.AddHabit(model.Habit{ID: "1", Name: "run"})
+	store.AddHabit(model.Habit{ID: "2", Name: "Run"})
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"list"}, ExitOK},
+		{[]string{"done", "swim"}, ExitNotFound},
+		{[]string{"done", "run"}, ExitNotFound}, // ambiguous
+		{[]string{"done", "2"}, ExitOK},
+		{[]string{"done"}, ExitUsage},
+		{[]string{"done", "1", "--date", "March"}, ExitUsage},
+		{[]string{"list", "--bogus"}, ExitUsage},
+		{[]string{"task", "frobnicate"}, ExitUsage},
+		{[]string{"nope"}, ExitUsage},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := run(t, store, tt.args...); code != tt.want {
+			t.Errorf("%v: exit %d, want %d (%s)", tt.args, code, tt.want, stderr)
+		}
+	}
+}
+
+func TestListAndArchive(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	store.AddHabit(model.Habit{ID: "1", Name: "stretch"})
+	store.AddHabit(model.Habit{ID: "2", Name: "smoking", Kind: model.KindQuit})
+	store.ToggleHabitCompletion("1", "2024-03-01")
+
+	_, out, _ := run(t, store, "list", "--date", "2024-03-01")
+	for _, want := range []string{"done", "stretch", "clean", "smoking"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in list output:\n%s", want, out)
+		}
+	}
+
+	run(t, store, "archive", "stretch")
+	if _, out, _ = run(t, store, "list"); strings.Contains(out, "stretch") {
+		t.Fatalf("archived habit still listed:\n%s", out)
+	}
+	if _, out, _ = run(t, store, "list", "--archived"); !strings.Contains(out, "stretch") {
+		t.Fatalf("expected archived habit with --archived:\n%s", out)
+	}
+}
+
+func TestDeleteRestoreAndPurge(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	store.AddHabit(model.Habit{ID: "1", Name: "stretch"})
+	store.ToggleHabitCompletion("1", "2024-03-01")
+	store.ToggleHabitCompletion("1", "2024-03-02")
+	run(t, store, "archive", "stretch")
+
+	code, out, _ := run(t, store, "delete", "stretch")
+	if code != ExitOK || !strings.Contains(out, "2 completions") {
+		t.Fatalf("expected delete to report the completions, got %d: %s", code, out)
+	}
+	if _, out, _ = run(t, store, "list", "--archived"); strings.Contains(out, "stretch") {
+		t.Fatalf("deleted habit still listed as archived:\n%s", out)
+	}
+	if _, out, _ = run(t, store, "list", "--deleted"); !strings.Contains(out, "stretch") {
+		t.Fatalf("expected deleted habit with --deleted:\n%s", out)
+	}
+
+	if _, out, _ = run(t, store, "purge"); !strings.Contains(out, "nothing was deleted") {
+		t.Fatalf("expected purge to wait out the grace period:\n%s", out)
+	}
+	if code, _, _ = run(t, store, "restore", "stretch"); code != ExitOK {
+		t.Fatalf("restore exited %d", code)
+	}
+	if done, _ := store.IsHabitCompleted("1", "2024-03-01"); !done {
+		t.Fatal("expected restore to bring the history back")
+	}
+
+	run(t, store, "delete", "1")
+	if _, out, _ = run(t, store, "purge", "--all"); !strings.Contains(out, "purged stretch") {
+		t.Fatalf("expected purge --all to purge straight away:\n%s", out)
+	}
+	if code, _, _ = run(t, store, "restore", "stretch"); code != ExitNotFound {
+		t.Fatalf("expected nothing to restore after purging, got %d", code)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+
+	run(t, store, "task", "add", "file", "taxes", "--due", "2024-04-15")
+	run(t, store, "task", "add", "call mum")
+	if code, _, _ := run(t, store, "task", "done", "file taxes"); code != ExitOK {
+		t.Fatalf("task done exited %d", code)
+	}
+
+	_, out, _ := run(t, store, "task", "list")
+	if strings.Contains(out, "file taxes") || !strings.Contains(out, "call mum") {
+		t.Fatalf("expected only pending tasks:\n%s", out)
+	}
+	_, out, _ = run(t, store, "task", "list", "--all")
+	if !strings.Contains(out, "[x]") || !strings.Contains(out, "2024-04-15") {
+		t.Fatalf("expected completed task with --all:\n%s", out)
+	}
+}
+
+func TestListFormats(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	water, _ := model.ParseGoal("8 glasses/day")
+	store.AddHabit(model.Habit{ID: "1", Name: "water", Goal: water})
+	store.SetHabitValue("1", "2024-03-01", 3)
+
+	_, out, _ := run(t, store, "list", "--date", "2024-03-01", "--format", "json")
+	var doc HabitList
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if doc.SchemaVersion != SchemaVersion || doc.Date != "2024-03-01" || len(doc.Habits) != 1 {
+		t.Fatalf("unexpected document: %+v", doc)
+	}
+	if h := doc.Habits[0]; h.Name != "water" || h.Status != "partial" || h.Value == nil || *h.Value != 3 || h.Goal.Target != 8 {
+		t.Fatalf("unexpected habit: %+v", h)
+	}
+	if !strings.Contains(out, `"schedule": {
+        "kind": "daily"
+      }`) {
+		t.Fatalf("expected the schedule kind even for a habit saved without one:\n%s", out)
+	}
+
+	_, out, _ = run(t, store, "list", "--date", "2024-03-01", "--format", "csv")
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil || len(records) != 2 || records[0][0] != "id" || records[1][5] != "partial" || records[1][6] != "3" {
+		t.Fatalf("unexpected CSV (%v):\n%s", err, out)
+	}
+
+	if code, _, _ := run(t, store, "list", "--format", "yaml"); code != ExitUsage {
+		t.Fatalf("expected unknown format to be a usage error, got %d", code)
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	t.Parallel()
+	store := model.NewMemoryStore(model.Clock{})
+	store.AddHabit(model.Habit{ID: "1", Name: "smoking", Kind: model.KindQuit})
+
+	_, out, _ := run(t, store, "stats", "--format", "json")
+	var doc StatsList
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(doc.Stats) != 1 || doc.Stats[0].Kind != "quit" || doc.Stats[0].Unit != "day" {
+		t.Fatalf("unexpected stats: %+v", doc)
+	}
+	if !strings.Contains(out, `"current": `) {
+		t.Fatalf("expected streak fields inline:\n%s", out)
+	}
+}
@@ -0,0 +1,222 @@
+// File: cli/format.go
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"habit-tracker/model"
+)
+
+// SchemaVersion versions the JSON documents below. Adding a field keeps the
+// version; renaming, removing or changing the meaning of one bumps it. The
+// documents are described with examples in docs/json-output.md.
+const SchemaVersion = 1
+
+// HabitList is printed by `habit list --format json`.
+type HabitList struct {
+	SchemaVersion int           `json:"schema_version"`
+	Date          string        `json:"date"`
+	Habits        []HabitOnDate `json:"habits"`
+}
+
+// HabitOnDate is a habit with its state on the list's date. Its fields are
+// copied from model.Habit one by one so the document only changes when
+// this file does.
+type HabitOnDate struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Type        string            `json:"type"`
+	Notes       map[string]string `json:"notes"`
+	Archived    bool              `json:"archived"`
+	Schedule    Schedule          `json:"schedule"`
+	Goal        *Goal             `json:"goal,omitempty"`
+	Kind        string            `json:"kind,omitempty"` // quit, or left out for habits being built
+	CreatedAt   string            `json:"created_at,omitempty"`
+	// Status is done, partial (some progress towards a goal), pending,
+	// not-due, or for quit habits clean or relapsed.
+	Status string `json:"status"`
+	// Value is what counts towards the goal on that date: the day's value,
+	// or the week's total for weekly goals. Only set for habits with a goal.
+	Value *float64 `json:"value,omitempty"`
+}
+
+// Schedule is a habit's schedule. Kind is always set, daily included; the
+// other fields depend on it.
+type Schedule struct {
+	Kind     string         `json:"kind"`
+	Weekdays []time.Weekday `json:"weekdays,omitempty"` // 0 is Sunday
+	Times    int            `json:"times,omitempty"`
+	Every    int            `json:"every,omitempty"`
+	Anchor   string         `json:"anchor,omitempty"`
+}
+
+// Goal is a quantitative habit's target.
+type Goal struct {
+	Unit        string  `json:"unit"`
+	Target      float64 `json:"target"`
+	Per         string  `json:"per"`
+	Aggregation string  `json:"aggregation"`
+}
+
+func habitOnDate(h model.Habit) HabitOnDate {
+	row := HabitOnDate{
+		ID:          h.ID,
+		Name:        h.Name,
+		Description: h.Description,
+		Type:        h.Type,
+		Notes:       h.Notes,
+		Archived:    h.Archived,
+		Schedule: Schedule{
+			Kind:     h.Schedule.Kind,
+			Weekdays: h.Schedule.Weekdays,
+			Times:    h.Schedule.Times,
+			Every:    h.Schedule.Every,
+			Anchor:   h.Schedule.Anchor,
+		},
+		Kind:      h.Kind,
+		CreatedAt: h.CreatedAt,
+	}
+	if row.Schedule.Kind == "" {
+		row.Schedule.Kind = model.ScheduleDaily // habits from before schedules
+	}
+	if g := h.Goal; g != nil {
+		row.Goal = &Goal{Unit: g.Unit, Target: g.Target, Per: g.Per, Aggregation: g.Aggregation}
+	}
+	return row
+}
+
+// StatsList is printed by `habit stats --format json`.
+type StatsList struct {
+	SchemaVersion int          `json:"schema_version"`
+	Stats         []HabitStats `json:"stats"`
+}
+
+// HabitStats is one habit's streak summary. For quit habits Current and
+// Longest are days clean and Total is the number of relapses.
+type HabitStats struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Kind         string  `json:"kind"` // build or quit
+	Current      int     `json:"current"`
+	Longest      int     `json:"longest"`
+	LongestStart string  `json:"longest_start,omitempty"`
+	LongestEnd   string  `json:"longest_end,omitempty"`
+	Total        int     `json:"total"`
+	Unit         string  `json:"unit"`
+	LastRelapse  string  `json:"last_relapse,omitempty"`
+	RelapseRate  float64 `json:"relapses_per_30_days,omitempty"`
+}
+
+func habitStats(h model.Habit, st model.StreakStats) HabitStats {
+	return HabitStats{
+		ID:           h.ID,
+		Name:         h.Name,
+		Kind:         kindName(h),
+		Current:      st.Current,
+		Longest:      st.Longest,
+		LongestStart: st.LongestStart,
+		LongestEnd:   st.LongestEnd,
+		Total:        st.Total,
+		Unit:         st.Unit,
+		LastRelapse:  st.LastRelapse,
+		RelapseRate:  st.RelapseRate,
+	}
+}
+
+// TaskList is printed by `habit task list --format json`.
+type TaskList struct {
+	SchemaVersion int    `json:"schema_version"`
+	Tasks         []Task `json:"tasks"`
+}
+
+type Task struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	Completed   bool   `json:"completed"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func task(t model.Task) Task {
+	return Task{ID: t.ID, Name: t.Name, Description: t.Description, DueDate: t.DueDate, Completed: t.Completed, CreatedAt: t.CreatedAt}
+}
+
+// EventList is printed by `habit log --format json`, newest event first.
+type EventList struct {
+	SchemaVersion int     `json:"schema_version"`
+	Events        []Event `json:"events"`
+}
+
+type Event struct {
+	Seq     uint64          `json:"seq"`
+	At      string          `json:"at"` // RFC 3339, in UTC
+	Action  string          `json:"action"`
+	HabitID string          `json:"habit_id,omitempty"`
+	TaskID  string          `json:"task_id,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Date    string          `json:"date,omitempty"`
+	Payload json.RawMessage `json:"payload"` // the record afterwards; null once it's gone
+}
+
+func event(ev model.Event) Event {
+	return Event{Seq: ev.Seq, At: ev.At, Action: ev.Action, HabitID: ev.HabitID, TaskID: ev.TaskID, Name: ev.Name, Date: ev.Date, Payload: ev.Payload}
+}
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", formatTable, "output format: table, json or csv")
+}
+
+func checkFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return nil
+	}
+	return usagef("unknown format %q: want table, json or csv", format)
+}
+
+// output is a query result in every format it can be printed in.
+type output struct {
+	json  any
+	csv   [][]string // header row first
+	table [][]string // aligned into columns, no header
+}
+
+func (e *env) print(format string, out output) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out.json)
+	case formatCSV:
+		w := csv.NewWriter(e.stdout)
+		w.WriteAll(out.csv)
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		for _, row := range out.table {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+}
+
+func kindName(h model.Habit) string {
+	if h.IsQuit() {
+		return "quit"
+	}
+	return "build"
+}
@@ -0,0 +1,275 @@
+// File: config/config.go
+
+// Package config locates the tracker's files and reads its settings from
+// $XDG_CONFIG_HOME/habit-cmd/config.toml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"habit-tracker/model"
+)
+
+const appName = "habit-cmd"
+
+// Config is the contents of config.toml. Settings missing from the file, or
+// the whole file if it is missing, take the values in Default.
+type Config struct {
+	// DB is the database path. Relative paths are relative to the config
+	// directory and "~/" means the home directory.
+	DB string `toml:"db"`
+
+	WeekStart        string `toml:"week_start"`         // a day name, e.g. "monday"
+	DefaultView      string `toml:"default_view"`       // the tab the TUI opens on
+	RolloverHour     int    `toml:"rollover_hour"`      // see model.Clock
+	Timezone         string `toml:"timezone"`           // IANA name; empty means the system timezone
+	DefaultHabitType string `toml:"default_habit_type"` // preselected when adding a habit: build or quit
+
+	Dates  Dates  `toml:"dates"`
+	Colors Colors `toml:"colors"`
+
+	// LogFile, if set, is where the TUI writes the full text of errors it
+	// shows in its status line. Paths resolve like DB.
+	LogFile string `toml:"log_file"`
+
+	// KeyPreset names the starting keybindings, "default" or "vim", and
+	// Keys rebinds actions, e.g. archive = ["x"]. See Actions.
+	KeyPreset string              `toml:"key_preset"`
+	Keys      map[string][]string `toml:"keys"`
+
+	path string // the file this was read from
+}
+
+// Dates are Go time layouts, written against Mon Jan 2 2006.
+type Dates struct {
+	Day   string `toml:"day"`   // headings for a single day
+	Month string `toml:"month"` // calendar titles
+}
+
+// Colors are ANSI color numbers ("0" to "255") or hex codes ("#ff8800").
+type Colors struct {
+	Completed            string `toml:"completed"`
+	CompletedBackground  string `toml:"completed_background"`
+	Incomplete           string `toml:"incomplete"`
+	IncompleteBackground string `toml:"incomplete_background"`
+	Selected             string `toml:"selected"`
+	SelectedBackground   string `toml:"selected_background"`
+	Border               string `toml:"border"`
+	Today                string `toml:"today"`
+	Overdue              string `toml:"overdue"`
+	Error                string `toml:"error"`
+	Controls             string `toml:"controls"`
+}
+
+// Views are the TUI tabs default_view may name.
+var Views = []string{"week", "habits", "tasks", "stats", "archived"}
+
+func Default() Config {
+	return Config{
+		WeekStart:        "sunday",
+		DefaultView:      "week",
+		DefaultHabitType: "build",
+		KeyPreset:        "default",
+		Dates: Dates{
+			Day:   "Mon Jan 02",
+			Month: "January 2006",
+		},
+		Colors: Colors{
+			Completed:            "10",
+			CompletedBackground:  "22",
+			Incomplete:           "8",
+			IncompleteBackground: "0",
+			Selected:             "15",
+			SelectedBackground:   "4",
+			Border:               "6",
+			Today:                "2",
+			Overdue:              "9",
+			Error:                "9",
+			Controls:             "7",
+		},
+	}
+}
+
+// Dir is $XDG_CONFIG_HOME/habit-cmd, falling back to ~/.config/habit-cmd.
+func Dir() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// DataDir is $XDG_DATA_HOME/habit-cmd, falling back to ~/.local/share/habit-cmd.
+func DataDir() (string, error) {
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find home directory for $%s default: %w", env, err)
+	}
+	return filepath.Join(home, fallback, appName), nil
+}
+
+// Path is the config file's location, whether or not it exists.
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// Load reads and validates the config file. Unknown keys are an error, so
+// that a typo doesn't silently fall back to a default.
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+	return LoadFile(path)
+}
+
+func LoadFile(path string) (Config, error) {
+	c := Default()
+	c.path = path
+	md, err := toml.DecodeFile(path, &c)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.path = ""
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return c, fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+	}
+	if err := c.Validate(); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// File is the path the config was read from, or "" if there was no file.
+func (c Config) File() string {
+	return c.path
+}
+
+// Validate checks every setting and reports all the problems at once.
+func (c Config) Validate() error {
+	var errs []error
+	if _, ok := parseWeekday(c.WeekStart); !ok {
+		errs = append(errs, fmt.Errorf("week_start: want a day name such as \"monday\", got %q", c.WeekStart))
+	}
+	if !slices.Contains(Views, c.DefaultView) {
+		errs = append(errs, fmt.Errorf("default_view: want one of %s, got %q", strings.Join(Views, ", "), c.DefaultView))
+	}
+	if c.RolloverHour < 0 || c.RolloverHour > 23 {
+		errs = append(errs, fmt.Errorf("rollover_hour: want 0 to 23, got %d", c.RolloverHour))
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("timezone: unknown timezone %q", c.Timezone))
+		}
+	}
+	if c.DefaultHabitType != "build" && c.DefaultHabitType != "quit" {
+		errs = append(errs, fmt.Errorf("default_habit_type: want build or quit, got %q", c.DefaultHabitType))
+	}
+	for key, layout := range map[string]string{"dates.day": c.Dates.Day, "dates.month": c.Dates.Month} {
+		// A layout without any of Go's reference values prints itself.
+		if layout == "" || time.Date(1999, 11, 28, 13, 14, 15, 0, time.UTC).Format(layout) == layout {
+			errs = append(errs, fmt.Errorf("%s: %q is not a date layout; write it against Mon Jan 2 2006, e.g. \"Mon Jan 02\"", key, layout))
+		}
+	}
+	colors := reflect.ValueOf(c.Colors)
+	for i := range colors.NumField() {
+		key := colors.Type().Field(i).Tag.Get("toml")
+		if value := colors.Field(i).String(); !validColor(value) {
+			errs = append(errs, fmt.Errorf("colors.%s: want an ANSI number 0-255 or a hex code like \"#ff8800\", got %q", key, value))
+		}
+	}
+	errs = append(errs, c.validateKeys()...)
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func validColor(s string) bool {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n >= 0 && n <= 255
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	_, err := strconv.ParseUint(s[1:], 16, 32)
+	return err == nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) || strings.EqualFold(d.String()[:3], name) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Clock is the model.Clock these settings describe. c must be valid.
+func (c Config) Clock() (model.Clock, error) {
+	clock := model.Clock{RolloverHour: c.RolloverHour}
+	clock.WeekStart, _ = parseWeekday(c.WeekStart)
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return clock, fmt.Errorf("timezone: %w", err)
+		}
+		clock.Location = loc
+	}
+	return clock, nil
+}
+
+// Show writes the effective settings as TOML, listing every action's keys.
+// c must be valid.
+func (c Config) Show(w io.Writer) error {
+	c.Keys = c.KeyMap()
+	for _, keys := range c.Keys {
+		for i, k := range keys {
+			keys[i] = KeyName(k)
+		}
+	}
+	return toml.NewEncoder(w).Encode(c)
+}
+
+// LogPath is the resolved log_file, or "" if there is none.
+func (c Config) LogPath() (string, error) {
+	if c.LogFile == "" {
+		return "", nil
+	}
+	return c.resolve(c.LogFile)
+}
+
+// resolve makes a path from the config file absolute.
+func (c Config) resolve(p string) (string, error) {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if filepath.IsAbs(p) || c.path == "" {
+		return p, nil
+	}
+	return filepath.Join(filepath.Dir(c.path), p), nil
+}
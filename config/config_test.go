@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate points the XDG directories and the working directory at a fresh
+// temporary directory and clears HABIT_DB.
+func isolate(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	t.Setenv("HABIT_DB", "")
+	t.Chdir(dir)
+	return dir
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0700)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDBPathPrecedence(t *testing.T) {
+	dir := isolate(t)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("missing config file should load: %v", err)
+	}
+	loc, _ := cfg.DBPath("")
+	if want := filepath.Join(dir, "data", "habit-cmd", "tracker.db"); loc.Path != want || !loc.IsDefault() {
+		t.Fatalf("expected default %s, got %+v", want, loc)
+	}
+
+	path := writeConfig(t, `db = "habits.db"`)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	loc, _ = cfg.DBPath("")
+	if want := filepath.Join(dir, "config", "habit-cmd", "habits.db"); loc.Path != want || loc.Source != path {
+		t.Fatalf("expected config file path %s relative to the config dir, got %+v", want, loc)
+	}
+
+	t.Setenv("HABIT_DB", "/tmp/env.db")
+	if loc, _ = cfg.DBPath(""); loc.Path != "/tmp/env.db" || loc.Source != "HABIT_DB" {
+		t.Fatalf("expected HABIT_DB to beat the config file, got %+v", loc)
+	}
+	if loc, _ = cfg.DBPath("flag.db"); loc.Path != "flag.db" || loc.Source != "--db" {
+		t.Fatalf("expected --db to beat everything, got %+v", loc)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	isolate(t)
+	path := writeConfig(t, `databse = "typo.db"`)
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "databse") {
+		t.Fatalf("expected an error naming the file and key, got %v", err)
+	}
+}
+
+func TestLegacyDBOfferedOnce(t *testing.T) {
+	isolate(t)
+	cfg, _ := Load()
+	loc, _ := cfg.DBPath("")
+	if LegacyDBPending(loc) {
+		t.Fatal("nothing to offer without ./tracker.db")
+	}
+
+	os.WriteFile(DBFileName, []byte("data"), 0600)
+	if !LegacyDBPending(loc) {
+		t.Fatal("expected an offer for ./tracker.db")
+	}
+	if explicit, _ := cfg.DBPath("other.db"); LegacyDBPending(explicit) {
+		t.Fatal("an explicit --db should not trigger the offer")
+	}
+
+	if err := MoveDB(DBFileName, loc.Path); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if data, err := os.ReadFile(loc.Path); err != nil || string(data) != "data" {
+		t.Fatalf("expected the database at %s, got %q, %v", loc.Path, data, err)
+	}
+	if _, err := os.Stat(DBFileName); !os.IsNotExist(err) {
+		t.Fatal("expected ./tracker.db to be gone")
+	}
+
+	// Declining leaves both files where they are; the marker stops the offer.
+	os.Remove(loc.Path)
+	os.WriteFile(DBFileName, []byte("data"), 0600)
+	if err := MarkLegacyDBOffered(loc); err != nil {
+		t.Fatal(err)
+	}
+	if LegacyDBPending(loc) {
+		t.Fatal("expected no second offer")
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	isolate(t)
+	writeConfig(t, `
+week_start = "monday"
+default_view = "habits"
+rollover_hour = 4
+default_habit_type = "quit"
+log_file = "tui.log"
+
+[dates]
+day = "02/01"
+
+[colors]
+selected = "#ff8800"
+`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DefaultView != "habits" || cfg.Dates.Day != "02/01" || cfg.Colors.Selected != "#ff8800" {
+		t.Fatalf("settings not read: %+v", cfg)
+	}
+	if cfg.Dates.Month != Default().Dates.Month || cfg.Colors.Border != Default().Colors.Border {
+		t.Fatalf("expected missing settings to keep their defaults: %+v", cfg)
+	}
+	if path, _ := cfg.LogPath(); path != filepath.Join(filepath.Dir(cfg.File()), "tui.log") {
+		t.Fatalf("expected log_file relative to the config file, got %q", path)
+	}
+	clock, err := cfg.Clock()
+	if err != nil || clock.WeekStart != time.Monday || clock.RolloverHour != 4 {
+		t.Fatalf("unexpected clock %+v (%v)", clock, err)
+	}
+
+	var out strings.Builder
+	if err := cfg.Show(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`week_start = "monday"`, `rollover_hour = 4`, `[colors]`, `border = "6"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	isolate(t)
+	writeConfig(t, `
+week_start = "someday"
+default_view = "calendar"
+rollover_hour = 25
+default_habit_type = "maybe"
+
+[dates]
+month = "the month"
+
+[colors]
+today = "green"
+`)
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, key := range []string{"week_start", "default_view", "rollover_hour", "default_habit_type", "dates.month", "colors.today"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("expected a message for %s in:\n%v", key, err)
+		}
+	}
+}
+
+func TestKeyBindings(t *testing.T) {
+	isolate(t)
+	writeConfig(t, `
+key_preset = "vim"
+
+[keys]
+archive = ["D"]
+toggle = ["space", "y"]
+`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	keys := cfg.KeyMap()
+	if !slices.Equal(keys["down"], []string{"j", "down"}) || !slices.Equal(keys["archive"], []string{"D"}) || !slices.Equal(keys["toggle"], []string{" ", "y"}) {
+		t.Fatalf("unexpected bindings: %v", keys)
+	}
+	if !slices.Equal(keys["edit"], []string{"e"}) {
+		t.Fatalf("expected unbound actions to keep the preset's keys, got %v", keys["edit"])
+	}
+
+	writeConfig(t, `
+[keys]
+archive = ["u"]
+confirm = ["y"]
+jump = ["g"]
+`)
+	if _, err = Load(); err == nil || !strings.Contains(err.Error(), "keys.jump: unknown action") {
+		t.Fatalf("expected an unknown action error, got %v", err)
+	}
+	writeConfig(t, `
+[keys]
+archive = ["u"]
+confirm = ["y"]
+`)
+	_, err = Load()
+	for _, want := range []string{`keys.unarchive: "u" is also bound to archive`, `keys.undo: "u" is also bound to archive`, `keys.confirm: "y" types a character`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
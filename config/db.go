@@ -0,0 +1,112 @@
+// File: config/db.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DBFileName is the database's file name, both in the data directory and
+// in the working directory where older builds created it.
+const DBFileName = "tracker.db"
+
+// DBLocation is the resolved database path and what chose it.
+type DBLocation struct {
+	Path   string
+	Source string // "--db", "HABIT_DB", the config file's path, or "default"
+}
+
+// IsDefault reports whether nothing chose the path explicitly.
+func (l DBLocation) IsDefault() bool {
+	return l.Source == "default"
+}
+
+// DBPath picks the database from, in order: flagValue (the --db flag), the
+// HABIT_DB environment variable, the config file's db setting and
+// $XDG_DATA_HOME/habit-cmd/tracker.db.
+func (c Config) DBPath(flagValue string) (DBLocation, error) {
+	if flagValue != "" {
+		return DBLocation{Path: flagValue, Source: "--db"}, nil
+	}
+	if env := os.Getenv("HABIT_DB"); env != "" {
+		return DBLocation{Path: env, Source: "HABIT_DB"}, nil
+	}
+	if c.DB != "" {
+		path, err := c.resolve(c.DB)
+		return DBLocation{Path: path, Source: c.path}, err
+	}
+	dir, err := DataDir()
+	if err != nil {
+		return DBLocation{}, err
+	}
+	return DBLocation{Path: filepath.Join(dir, DBFileName), Source: "default"}, nil
+}
+
+// LegacyDBPending reports whether there is a ./tracker.db left by a build
+// that kept the database in the working directory, the default location
+// is still empty, and the user hasn't been asked about moving it before.
+func LegacyDBPending(loc DBLocation) bool {
+	if !loc.IsDefault() || exists(loc.Path) || !exists(DBFileName) {
+		return false
+	}
+	return !exists(offeredMarker(loc))
+}
+
+// MarkLegacyDBOffered records that the user was asked, so they aren't
+// asked again whatever they answered.
+func MarkLegacyDBOffered(loc DBLocation) error {
+	marker := offeredMarker(loc)
+	if err := os.MkdirAll(filepath.Dir(marker), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(marker, nil, 0600)
+}
+
+func offeredMarker(loc DBLocation) string {
+	return filepath.Join(filepath.Dir(loc.Path), ".legacy-db-offered")
+}
+
+// MoveDB moves the database at from to to, copying when they are on
+// different filesystems. It refuses to overwrite an existing file.
+func MoveDB(from, to string) error {
+	if exists(to) {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyFile(from, to); err != nil {
+		os.Remove(to)
+		return err
+	}
+	return os.Remove(from)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
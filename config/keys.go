@@ -0,0 +1,141 @@
+// File: config/keys.go
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Actions are the TUI commands keys are bound to, in the order help lists
+// them.
+var Actions = []string{
+	"left", "right", "prev_week", "next_week", "today", "up", "down", "next_view",
+	"toggle", "increment", "decrement", "note", "edit", "add",
+	"archive", "unarchive", "archived", "calendar", "delete", "undo", "redo",
+	"confirm", "cancel", "delete_char", "help", "quit",
+}
+
+// textActions still apply while typing into a field, so they can't be
+// bound to keys that type a character.
+var textActions = []string{"confirm", "cancel", "delete_char"}
+
+// sharedActions may be bound to the same key because the TUI tells them
+// apart by view: the key unarchives in the archived tab and undoes
+// everywhere else.
+var sharedActions = [][2]string{{"undo", "unarchive"}}
+
+var defaultKeys = map[string][]string{
+	"left":        {"left"},
+	"right":       {"right"},
+	"prev_week":   {"["},
+	"next_week":   {"]"},
+	"today":       {"t"},
+	"up":          {"up"},
+	"down":        {"down"},
+	"next_view":   {"tab"},
+	"toggle":      {"space"},
+	"increment":   {"+", "="},
+	"decrement":   {"-"},
+	"note":        {"n"},
+	"edit":        {"e"},
+	"add":         {"a"},
+	"archive":     {"d"},
+	"unarchive":   {"u"},
+	"archived":    {"v"},
+	"calendar":    {"c"},
+	"delete":      {"x"},
+	"undo":        {"u", "ctrl+z"},
+	"redo":        {"ctrl+r"},
+	"confirm":     {"enter"},
+	"cancel":      {"esc"},
+	"delete_char": {"backspace"},
+	"help":        {"?"},
+	"quit":        {"ctrl+c"},
+}
+
+// Presets are the key_preset choices. Keys in [keys] replace the preset's
+// keys for the actions they name.
+var Presets = map[string]map[string][]string{
+	"default": defaultKeys,
+	"vim": withKeys(defaultKeys, map[string][]string{
+		"left":  {"h", "left"},
+		"right": {"l", "right"},
+		"up":    {"k", "up"},
+		"down":  {"j", "down"},
+		"quit":  {"q", "ctrl+c"},
+	}),
+}
+
+func withKeys(base, overrides map[string][]string) map[string][]string {
+	keys := make(map[string][]string, len(base))
+	for action, k := range base {
+		keys[action] = k
+	}
+	for action, k := range overrides {
+		keys[action] = k
+	}
+	return keys
+}
+
+// KeyMap is the keys bound to each action: the preset's, with the [keys]
+// overrides applied. "space" is returned as " ", the name bubbletea uses.
+// c must be valid.
+func (c Config) KeyMap() map[string][]string {
+	keys := withKeys(Presets[c.KeyPreset], c.Keys)
+	for action, k := range keys {
+		keys[action] = slices.Clone(k)
+		for i := range k {
+			if k[i] == "space" {
+				keys[action][i] = " "
+			}
+		}
+	}
+	return keys
+}
+
+func (c Config) validateKeys() []error {
+	if _, ok := Presets[c.KeyPreset]; !ok {
+		return []error{fmt.Errorf("key_preset: want default or vim, got %q", c.KeyPreset)}
+	}
+	var errs []error
+	for action, k := range c.Keys {
+		if !slices.Contains(Actions, action) {
+			errs = append(errs, fmt.Errorf("keys.%s: unknown action; want one of %s", action, strings.Join(Actions, ", ")))
+		} else if len(k) == 0 || slices.Contains(k, "") {
+			errs = append(errs, fmt.Errorf("keys.%s: want at least one key and no empty ones", action))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	bound := make(map[string]string) // key -> action
+	keys := c.KeyMap()
+	for _, action := range Actions {
+		for _, k := range keys[action] {
+			if other, ok := bound[k]; ok && !mayShare(other, action) {
+				errs = append(errs, fmt.Errorf("keys.%s: %q is also bound to %s", action, KeyName(k), other))
+				continue
+			}
+			bound[k] = action
+			if slices.Contains(textActions, action) && utf8.RuneCountInString(k) == 1 {
+				errs = append(errs, fmt.Errorf("keys.%s: %q types a character; use a key such as \"enter\" or \"ctrl+s\"", action, KeyName(k)))
+			}
+		}
+	}
+	return errs
+}
+
+func mayShare(a, b string) bool {
+	return slices.Contains(sharedActions, [2]string{a, b}) || slices.Contains(sharedActions, [2]string{b, a})
+}
+
+// KeyName is how a key is written in the config file.
+func KeyName(k string) string {
+	if k == " " {
+		return "space"
+	}
+	return k
+}
@@ -2,9 +2,183 @@
 package main
 
 import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"habit-tracker/cli"
+	"habit-tracker/config"
+	"habit-tracker/model"
 	"habit-tracker/tui"
 )
 
 func main() {
-	tui.StartApp()
+	ephemeral := flag.Bool("ephemeral", false, "keep all data in memory; nothing is written to disk")
+	dbFlag := flag.String("db", "", "database file (default: $HABIT_DB, the config file, then $XDG_DATA_HOME/habit-cmd/tracker.db)")
+	rollover := flag.Int("rollover-hour", 0, "hour (0-23) at which a new day starts; e.g. 4 keeps 1am on the previous day (overrides the config file)")
+	timezone := flag.String("timezone", "", "home timezone, e.g. Europe/London (overrides the config file; default: the system timezone)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Flags given on the command line beat the config file.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "rollover-hour":
+			cfg.RolloverHour = *rollover
+		case "timezone":
+			cfg.Timezone = *timezone
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	clock, err := cfg.Clock()
+	if err == nil {
+		err = clock.Validate()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	loc, err := cfg.DBPath(*dbFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	command := flag.Arg(0)
+	switch {
+	case command == "":
+	case command == "migrate":
+		os.Exit(runMigrate(loc.Path, clock, flag.Args()[1:]))
+	case command == "config":
+		os.Exit(runConfig(cfg, loc, flag.Args()[1:]))
+	case cli.IsCommand(command):
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		cli.Usage(os.Stderr)
+		os.Exit(cli.ExitUsage)
+	}
+
+	var store model.Store
+	if *ephemeral {
+		store = model.NewMemoryStore(clock)
+	} else {
+		if command == "" {
+			offerLegacyMove(loc)
+		}
+		if err := os.MkdirAll(filepath.Dir(loc.Path), 0700); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create the data directory:", err)
+			os.Exit(1)
+		}
+		s, err := model.OpenBoltStore(loc.Path, clock)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to open DB:", err)
+			os.Exit(1)
+		}
+		store = s
+	}
+	if command != "" {
+		code := cli.Run(store, flag.Args(), os.Stdout, os.Stderr)
+		store.Close()
+		os.Exit(code)
+	}
+	defer store.Close()
+
+	tui.StartApp(store, cfg)
+}
+
+func runConfig(cfg config.Config, loc config.DBLocation, args []string) int {
+	if len(args) != 1 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "usage: habit config show")
+		return 2
+	}
+	file := cfg.File()
+	if file == "" {
+		path, _ := config.Path()
+		file = path + " (not found, using defaults)"
+	}
+	fmt.Printf("# config file: %s\n# database: %s (from %s)\n", file, loc.Path, loc.Source)
+	if err := cfg.Show(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		return 1
+	}
+	return 0
+}
+
+// offerLegacyMove asks, once, whether to move the ./tracker.db that older
+// builds created in the working directory into the default location. It
+// talks on stderr so nothing it says ends up in piped output.
+func offerLegacyMove(loc config.DBLocation) {
+	if !config.LegacyDBPending(loc) {
+		return
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return // not interactive; ask another time
+	}
+	fmt.Fprintf(os.Stderr, "Found ./%s from an older version. Move it to %s? [y/N] ", config.DBFileName, loc.Path)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err := config.MarkLegacyDBOffered(loc); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		if err := config.MoveDB(config.DBFileName, loc.Path); err != nil {
+			fmt.Fprintln(os.Stderr, "move failed:", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Moved to", loc.Path)
+	default:
+		fmt.Fprintf(os.Stderr, "Left it in place. Use --db %s or set db in the config file to keep using it.\n", config.DBFileName)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func runMigrate(dbPath string, clock model.Clock, args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report pending migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	plan, err := model.PlanBoltMigrations(dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Printf("%s is up to date (schema v%d)\n", dbPath, plan.From)
+		return 0
+	}
+	fmt.Printf("%s: schema v%d -> v%d\n", dbPath, plan.From, plan.To)
+	for _, step := range plan.Steps {
+		fmt.Printf("  v%d  %s\n", step.Version, step.Description)
+	}
+	if *dryRun {
+		fmt.Println("dry run: nothing changed")
+		return 0
+	}
+
+	result, err := model.MigrateBoltStore(dbPath, clock)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	if result.BackupPath != "" {
+		fmt.Println("backup written to", result.BackupPath)
+	}
+	fmt.Printf("migrated to schema v%d\n", result.To)
+	return 0
 }
@@ -0,0 +1,78 @@
+// File: model/clock.go
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Clock decides which calendar day it is. Each Store is opened with its
+// own; see Store.Clock. A day runs from RolloverHour to RolloverHour in
+// Location, so with a rollover of 4 a habit ticked off at 1am still counts
+// for the evening before. Travelling doesn't shift history as long as
+// Location stays the home timezone.
+//
+// Days are returned as midnight UTC times, the form the rest of the app
+// formats and steps through with AddDate.
+//
+// WeekStart is the first day of the week, for the week strip, the calendar
+// and the periods of weekly schedules and goals.
+type Clock struct {
+	Location     *time.Location // nil means time.Local
+	RolloverHour int
+	WeekStart    time.Weekday
+}
+
+func (c Clock) Validate() error {
+	if c.RolloverHour < 0 || c.RolloverHour > 23 {
+		return fmt.Errorf("rollover hour must be between 0 and 23, not %d", c.RolloverHour)
+	}
+	if c.WeekStart < time.Sunday || c.WeekStart > time.Saturday {
+		return fmt.Errorf("invalid week start %d", c.WeekStart)
+	}
+	return nil
+}
+
+// Home is the timezone c's days are counted in.
+func (c Clock) Home() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// DayOf returns the day the instant t belongs to.
+func (c Clock) DayOf(t time.Time) time.Time {
+	t = t.In(c.Home()).Add(-time.Duration(c.RolloverHour) * time.Hour)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// StartOfDay returns the instant day, as returned by DayOf, begins.
+func (c Clock) StartOfDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), c.RolloverHour, 0, 0, 0, c.Home())
+}
+
+// now is the current instant; tests may replace it.
+var now = time.Now
+
+// Today is the current day by c. Everything that needs "today"
+// (completions, streaks, the week strip, the calendar) asks the store's
+// clock.
+func (c Clock) Today() time.Time {
+	return c.DayOf(now())
+}
+
+// StartOfWeek returns the first day of the week containing day.
+func (c Clock) StartOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -weekOffset(day.Weekday(), c.WeekStart))
+}
+
+func (c Clock) todayNumber() int {
+	day, _ := dayNumber(c.Today().Format("2006-01-02"))
+	return day
+}
+
+// logTime timestamps a completion.
+func logTime() string {
+	return now().Format(time.RFC3339)
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClockDayOf(t *testing.T) {
+	t.Parallel()
+	home := time.FixedZone("home", -5*3600)
+
+	tests := []struct {
+		name  string
+		clock Clock
+		at    time.Time
+		want  string
+	}{
+		{"midnight rollover", Clock{Location: home}, time.Date(2024, 3, 10, 1, 0, 0, 0, home), "2024-03-10"},
+		{"before rollover hour", Clock{Location: home, RolloverHour: 4}, time.Date(2024, 3, 10, 1, 0, 0, 0, home), "2024-03-09"},
+		{"at rollover hour", Clock{Location: home, RolloverHour: 4}, time.Date(2024, 3, 10, 4, 0, 0, 0, home), "2024-03-10"},
+		{"instant read in home timezone", Clock{Location: home}, time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC), "2024-03-09"},
+		{"travelling keeps home day", Clock{Location: home}, time.Date(2024, 3, 10, 8, 0, 0, 0, time.FixedZone("away", 9*3600)), "2024-03-09"},
+	}
+	for _, tt := range tests {
+		got := tt.clock.DayOf(tt.at)
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got.Format("2006-01-02"), tt.want)
+		}
+		if got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("%s: expected midnight UTC, got %v", tt.name, got)
+		}
+	}
+}
+
+func TestOpenRejectsBadRollover(t *testing.T) {
+	t.Parallel()
+	if _, err := OpenBoltStore(filepath.Join(t.TempDir(), "tracker_test.db"), Clock{RolloverHour: 24}); err == nil {
+		t.Fatal("expected rollover hour 24 to be rejected")
+	}
+}
+
+func TestStoresKeepTheirOwnClock(t *testing.T) {
+	t.Parallel()
+	sunday := NewMemoryStore(Clock{})
+	monday := NewMemoryStore(Clock{WeekStart: time.Monday})
+	for _, s := range []Store{sunday, monday} {
+		s.AddHabit(Habit{ID: "1", Name: "gym", Schedule: Schedule{Kind: ScheduleWeekly, Times: 2}})
+		// 2024-03-02 is a Saturday and 2024-03-03 a Sunday.
+		s.ToggleHabitCompletion("1", "2024-03-02")
+		s.ToggleHabitCompletion("1", "2024-03-03")
+	}
+	if stats, _ := sunday.GetHabitStats("1"); stats.Longest != 0 {
+		t.Fatalf("Sunday-start weeks split the two days, got %+v", stats)
+	}
+	if stats, _ := monday.GetHabitStats("1"); stats.Longest != 1 {
+		t.Fatalf("Monday-start week holds both days, got %+v", stats)
+	}
+}
+
+func TestClockStartOfWeek(t *testing.T) {
+	t.Parallel()
+	c := Clock{WeekStart: time.Monday}
+	if start := c.StartOfWeek(time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)); start.Format("2006-01-02") != "2024-01-01" {
+		t.Fatalf("expected the week of Sun 2024-01-07 to start Mon 2024-01-01, got %s", start.Format("2006-01-02"))
+	}
+}
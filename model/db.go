@@ -2,73 +2,115 @@
 package model
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
 )
 
-var db *bolt.DB
-
 var (
 	habitsBucket      = []byte("habits")
 	completionsBucket = []byte("completions")
 	tasksBucket       = []byte("tasks")
+	streaksBucket     = []byte("streaks")
+	notesBucket       = []byte("notes")
+	historyBucket     = []byte("history")
+	eventsBucket      = []byte("events")
 )
 
-type Habit struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Type        string            `json:"type"` // "general" or "daily"
-	Notes       map[string]string `json:"notes"`
-	Archived    bool              `json:"archived"`
+// BoltStore is the bbolt-backed Store.
+type BoltStore struct {
+	db    *bolt.DB
+	clock Clock
 }
 
-type HabitCompletion struct {
-	HabitID string `json:"habit_id"`
-	Date    string `json:"date"`
-}
+var _ Store = (*BoltStore)(nil)
 
-type Task struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	DueDate     string `json:"due_date"`
-	Completed   bool   `json:"completed"`
-	CreatedAt   string `json:"created_at"`
+// OpenBoltStore opens (or creates) the database at path, migrating it to
+// the current schema version first. clock decides which day it is for
+// everything the store dates or counts.
+func OpenBoltStore(path string, clock Clock) (*BoltStore, error) {
+	if err := clock.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrate(db, path, clock); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := syncWeekStart(db, clock.WeekStart); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db, clock: clock}, nil
 }
 
-func InitDB(path string) error {
-	var err error
-	db, err = bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+var weekStartKey = []byte("week_start")
+
+// syncWeekStart rebuilds every streak index if the week start has changed
+// since they were built, because weekly periods depend on it.
+func syncWeekStart(db *bolt.DB, weekStart time.Weekday) error {
+	want := []byte(strconv.Itoa(int(weekStart)))
+	var built []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		built = append(built, tx.Bucket(metaBucket).Get(weekStartKey)...)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	if built == nil {
+		built = []byte("0") // indexed before the week start was configurable: Sunday
+	}
+	if bytes.Equal(built, want) {
+		return nil
+	}
 	return db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(habitsBucket)
-		if err != nil {
-			return err
-		}
-		_, err = tx.CreateBucketIfNotExists(completionsBucket)
+		err := tx.Bucket(habitsBucket).ForEach(func(k, v []byte) error {
+			var h Habit
+			if err := json.Unmarshal(v, &h); err != nil {
+				return err
+			}
+			return rebuildStreakIndex(tx, h, weekStart)
+		})
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucketIfNotExists(tasksBucket)
-		return err
+		return tx.Bucket(metaBucket).Put(weekStartKey, want)
 	})
 }
 
-func GetHabits() ([]Habit, error) {
+func (s *BoltStore) GetHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return !h.Archived && h.DeletedAt == "" })
+}
+
+func (s *BoltStore) GetArchivedHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return h.Archived && h.DeletedAt == "" })
+}
+
+func (s *BoltStore) GetDeletedHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return h.DeletedAt != "" })
+}
+
+func (s *BoltStore) habitsWhere(keep func(Habit) bool) ([]Habit, error) {
 	var habits []Habit
-	err := db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(habitsBucket)
 		return b.ForEach(func(k, v []byte) error {
 			var h Habit
 			if err := json.Unmarshal(v, &h); err != nil {
 				return err
 			}
-			if !h.Archived {
+			if keep(h) {
 				habits = append(habits, h)
 			}
 			return nil
@@ -77,156 +119,417 @@ func GetHabits() ([]Habit, error) {
 	return habits, err
 }
 
-func GetArchivedHabits() ([]Habit, error) {
-	var habits []Habit
-	err := db.View(func(tx *bolt.Tx) error {
+func (s *BoltStore) AddHabit(habit Habit) error {
+	if err := habit.validate(); err != nil {
+		return err
+	}
+	if habit.CreatedAt == "" {
+		habit.CreatedAt = s.clock.Today().Format("2006-01-02")
+	}
+	data, err := json.Marshal(habit)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(habitsBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var h Habit
-			if err := json.Unmarshal(v, &h); err != nil {
+		if err := b.Put([]byte(habit.ID), data); err != nil {
+			return err
+		}
+		return appendEvent(tx, habitEvent(EventHabitCreate, habit), habit)
+	})
+}
+
+// UpdateHabit re-indexes the habit's streaks when its schedule or goal
+// changes.
+func (s *BoltStore) UpdateHabit(id string, habit Habit) error {
+	if err := habit.validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(habit)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		old, err := loadHabit(tx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := tx.Bucket(habitsBucket).Put([]byte(id), data); err != nil {
+			return err
+		}
+		if !old.sameStreakRules(habit) {
+			if err := rebuildStreakIndex(tx, habit, s.clock.WeekStart); err != nil {
 				return err
 			}
-			if h.Archived {
-				habits = append(habits, h)
+		}
+		return appendEvent(tx, habitEvent(EventHabitUpdate, habit), habit)
+	})
+}
+
+func loadHabit(tx *bolt.Tx, id string) (Habit, error) {
+	var h Habit
+	v := tx.Bucket(habitsBucket).Get([]byte(id))
+	if v == nil {
+		return h, ErrNotFound
+	}
+	err := json.Unmarshal(v, &h)
+	return h, err
+}
+
+// Completions live in one nested bucket per habit inside completionsBucket,
+// keyed by "2006-01-02" date so cursor order is chronological.
+
+func (s *BoltStore) ToggleHabitCompletion(habitID, date string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.changeCompletion(tx, EventCompletionToggle, habitID, date, func(old *HabitCompletion) *HabitCompletion {
+			if old != nil {
+				return nil
 			}
-			return nil
+			return &HabitCompletion{HabitID: habitID, Date: date, LoggedAt: logTime()}
 		})
 	})
-	return habits, err
 }
 
-func AddHabit(id, name, description, habitType string, notes map[string]string) error {
-	h := Habit{ID: id, Name: name, Description: description, Type: habitType, Notes: notes, Archived: false}
-	data, err := json.Marshal(h)
-	if err != nil {
+func (s *BoltStore) SetHabitValue(habitID, date string, value float64) error {
+	if err := ValidateValue(value); err != nil {
 		return err
 	}
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(habitsBucket)
-		return b.Put([]byte(id), data)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.changeCompletion(tx, EventCompletionValue, habitID, date, func(*HabitCompletion) *HabitCompletion {
+			if value <= 0 {
+				return nil
+			}
+			return &HabitCompletion{HabitID: habitID, Date: date, Value: value, LoggedAt: logTime()}
+		})
 	})
 }
 
-func UpdateHabit(id string, habit Habit) error {
-	data, err := json.Marshal(habit)
+// changeCompletion replaces the habit's completion for date with whatever
+// change returns, nil meaning none, keeps the streak index in step and logs
+// the change as action.
+func (s *BoltStore) changeCompletion(tx *bolt.Tx, action, habitID, date string, change func(old *HabitCompletion) *HabitCompletion) error {
+	keyBytes := []byte(date)
+	day, err := dayNumber(date)
+	if err != nil {
+		return err
+	}
+	b, err := tx.Bucket(completionsBucket).CreateBucketIfNotExists([]byte(habitID))
+	if err != nil {
+		return err
+	}
+	habit, err := loadHabit(tx, habitID)
+	if errors.Is(err, ErrNotFound) {
+		habit.ID = habitID
+	} else if err != nil {
+		return err
+	}
+	index, err := loadStreakIndex(tx, habitID)
 	if err != nil {
 		return err
 	}
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(habitsBucket)
-		return b.Put([]byte(id), data)
-	})
-}
 
-func ToggleHabitCompletion(habitID, date string) error {
-	key := habitID + "_" + date
-	keyBytes := []byte(key)
-	
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(completionsBucket)
-		if b.Get(keyBytes) != nil {
-			return b.Delete(keyBytes)
+	var old *HabitCompletion
+	if v := b.Get(keyBytes); v != nil {
+		old = &HabitCompletion{}
+		if err := json.Unmarshal(v, old); err != nil {
+			return err
 		}
-		completion := HabitCompletion{HabitID: habitID, Date: date}
-		data, err := json.Marshal(completion)
+		index.Total--
+	}
+	c := change(old)
+	if c != nil {
+		data, err := json.Marshal(c)
 		if err != nil {
 			return err
 		}
-		return b.Put(keyBytes, data)
+		if err := b.Put(keyBytes, data); err != nil {
+			return err
+		}
+		index.Total++
+	} else if old != nil {
+		if err := b.Delete(keyBytes); err != nil {
+			return err
+		}
+	}
+	if old != nil || c != nil {
+		event := habitEvent(action, habit)
+		event.Date = date
+		if err := appendEvent(tx, event, c); err != nil {
+			return err
+		}
+	}
+
+	err = index.update(habit, day, s.clock.WeekStart, func(from, to string) ([]HabitCompletion, error) {
+		return completionsIn(b, from, to)
 	})
+	if err != nil {
+		return err
+	}
+	return saveStreakIndex(tx, habitID, index)
 }
 
-func IsHabitCompleted(habitID, date string) (bool, error) {
-	key := habitID + "_" + date
-	keyBytes := []byte(key)
-	
+func (s *BoltStore) IsHabitCompleted(habitID, date string) (bool, error) {
 	var exists bool
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(completionsBucket)
-		exists = b.Get(keyBytes) != nil
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(completionsBucket).Bucket([]byte(habitID))
+		exists = b != nil && b.Get([]byte(date)) != nil
 		return nil
 	})
 	return exists, err
 }
 
-func ArchiveHabit(id string) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(habitsBucket)
-		v := b.Get([]byte(id))
-		var h Habit
-		if err := json.Unmarshal(v, &h); err != nil {
+func (s *BoltStore) CompletionsBetween(habitID, from, to string) ([]HabitCompletion, error) {
+	var completions []HabitCompletion
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(completionsBucket).Bucket([]byte(habitID))
+		if b == nil {
+			return nil
+		}
+		var err error
+		completions, err = completionsIn(b, from, to)
+		return err
+	})
+	return completions, err
+}
+
+// completionsIn reads the completions in a habit's bucket between from and
+// to inclusive with a single cursor scan.
+func completionsIn(b *bolt.Bucket, from, to string) ([]HabitCompletion, error) {
+	var completions []HabitCompletion
+	c := b.Cursor()
+	for k, v := c.Seek([]byte(from)); k != nil && string(k) <= to; k, v = c.Next() {
+		var completion HabitCompletion
+		if err := json.Unmarshal(v, &completion); err != nil {
+			return nil, err
+		}
+		completions = append(completions, completion)
+	}
+	return completions, nil
+}
+
+// Day notes are laid out like completions: a nested bucket per habit inside
+// notesBucket, keyed by date.
+
+func (s *BoltStore) SetNote(habitID, date, text string) error {
+	if _, err := dayNumber(date); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		old, err := loadNote(tx, habitID, date)
+		if err != nil || old == text {
 			return err
 		}
-		h.Archived = true
-		data, err := json.Marshal(h)
-		if err != nil {
+		note := DayNote{HabitID: habitID, Date: date, Text: text}
+		if err := putNote(tx, note); err != nil {
 			return err
 		}
-		return b.Put([]byte(id), data)
+		habit, err := loadHabit(tx, habitID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		event := Event{Action: EventNoteSet, HabitID: habitID, Name: habit.Name, Date: date}
+		if text == "" {
+			return appendEvent(tx, event, nil)
+		}
+		return appendEvent(tx, event, note)
 	})
 }
 
-func UnarchiveHabit(id string) error {
-	return db.Update(func(tx *bolt.Tx) error {
+// loadNote returns the text of the habit's note on date, or "" if it has
+// none.
+func loadNote(tx *bolt.Tx, habitID, date string) (string, error) {
+	b := tx.Bucket(notesBucket).Bucket([]byte(habitID))
+	if b == nil {
+		return "", nil
+	}
+	data := b.Get([]byte(date))
+	if data == nil {
+		return "", nil
+	}
+	var note DayNote
+	if err := json.Unmarshal(data, &note); err != nil {
+		return "", fmt.Errorf("note %s/%s: %w", habitID, date, err)
+	}
+	return note.Text, nil
+}
+
+func putNote(tx *bolt.Tx, note DayNote) error {
+	b, err := tx.Bucket(notesBucket).CreateBucketIfNotExists([]byte(note.HabitID))
+	if err != nil {
+		return err
+	}
+	if note.Text == "" {
+		return b.Delete([]byte(note.Date))
+	}
+	data, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(note.Date), data)
+}
+
+func (s *BoltStore) NotesBetween(habitID, from, to string) ([]DayNote, error) {
+	var notes []DayNote
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(notesBucket).Bucket([]byte(habitID))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(from)); k != nil && string(k) <= to; k, v = c.Next() {
+			var note DayNote
+			if err := json.Unmarshal(v, &note); err != nil {
+				return err
+			}
+			notes = append(notes, note)
+		}
+		return nil
+	})
+	return notes, err
+}
+
+func (s *BoltStore) ArchiveHabit(id string) error {
+	return s.changeHabit(id, EventHabitArchive, func(h *Habit) { h.Archived = true })
+}
+
+func (s *BoltStore) UnarchiveHabit(id string) error {
+	return s.changeHabit(id, EventHabitUnarchive, func(h *Habit) { h.Archived = false })
+}
+
+func (s *BoltStore) DeleteHabit(id string) error {
+	return s.changeHabit(id, EventHabitDelete, func(h *Habit) { h.DeletedAt = now().Format(time.RFC3339) })
+}
+
+func (s *BoltStore) RestoreHabit(id string) error {
+	return s.changeHabit(id, EventHabitRestore, func(h *Habit) { h.DeletedAt = "" })
+}
+
+// changeHabit rewrites the stored habit with id after applying change, and
+// logs it as action. Nothing change touches may affect the streak index.
+func (s *BoltStore) changeHabit(id, action string, change func(h *Habit)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(habitsBucket)
 		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
 		var h Habit
 		if err := json.Unmarshal(v, &h); err != nil {
 			return err
 		}
-		h.Archived = false
+		change(&h)
 		data, err := json.Marshal(h)
 		if err != nil {
 			return err
 		}
-		return b.Put([]byte(id), data)
+		if err := b.Put([]byte(id), data); err != nil {
+			return err
+		}
+		return appendEvent(tx, habitEvent(action, h), h)
 	})
 }
 
-func DeleteHabitPermanently(id string) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		habitsB := tx.Bucket(habitsBucket)
-		completionsB := tx.Bucket(completionsBucket)
-		
-		if err := habitsB.Delete([]byte(id)); err != nil {
-			return err
-		}
-		
-		return completionsB.ForEach(func(k, v []byte) error {
-			var completion HabitCompletion
-			if err := json.Unmarshal(v, &completion); err != nil {
-				return nil
+func (s *BoltStore) PurgeDeletedHabits(cutoff time.Time) ([]Habit, error) {
+	var purged []Habit
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(habitsBucket).ForEach(func(k, v []byte) error {
+			var h Habit
+			if err := json.Unmarshal(v, &h); err != nil {
+				return err
 			}
-			if completion.HabitID == id {
-				return completionsB.Delete(k)
+			if deletedBefore(h, cutoff) {
+				purged = append(purged, h)
 			}
 			return nil
 		})
+		if err != nil {
+			return err
+		}
+		for _, h := range purged {
+			if err := deleteHabit(tx, h.ID); err != nil {
+				return err
+			}
+			if err := appendEvent(tx, habitEvent(EventHabitPurge, h), nil); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// deletedBefore reports whether h was deleted before cutoff. A deletion
+// time that doesn't parse counts as long ago.
+func deletedBefore(h Habit, cutoff time.Time) bool {
+	if h.DeletedAt == "" {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339, h.DeletedAt)
+	return err != nil || at.Before(cutoff)
 }
 
-func AddTask(id, name, description, dueDate string) error {
+func (s *BoltStore) DeleteHabitPermanently(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		h, err := loadHabit(tx, id)
+		if errors.Is(err, ErrNotFound) {
+			// clear out anything left behind under the ID, but log nothing
+			return deleteHabit(tx, id)
+		} else if err != nil {
+			return err
+		}
+		if err := deleteHabit(tx, id); err != nil {
+			return err
+		}
+		return appendEvent(tx, habitEvent(EventHabitPurge, h), nil)
+	})
+}
+
+// deleteHabit removes the habit with id along with its completions, notes
+// and streak index.
+func deleteHabit(tx *bolt.Tx, id string) error {
+	if err := tx.Bucket(habitsBucket).Delete([]byte(id)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(streaksBucket).Delete([]byte(id)); err != nil {
+		return err
+	}
+	for _, name := range [][]byte{completionsBucket, notesBucket} {
+		err := tx.Bucket(name).DeleteBucket([]byte(id))
+		if err != nil && !errors.Is(err, bolterrors.ErrBucketNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BoltStore) AddTask(id, name, description, dueDate string) error {
 	task := Task{
 		ID:          id,
 		Name:        name,
 		Description: description,
 		DueDate:     dueDate,
 		Completed:   false,
-		CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		CreatedAt:   now().Format("2006-01-02 15:04:05"),
 	}
 	data, err := json.Marshal(task)
 	if err != nil {
 		return err
 	}
-	return db.Update(func(tx *bolt.Tx) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(tasksBucket)
-		return b.Put([]byte(id), data)
+		if err := b.Put([]byte(id), data); err != nil {
+			return err
+		}
+		return appendEvent(tx, taskEvent(EventTaskCreate, task), task)
 	})
 }
 
-func GetTasks() ([]Task, error) {
+func (s *BoltStore) GetTasks() ([]Task, error) {
 	var tasks []Task
-	err := db.View(func(tx *bolt.Tx) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(tasksBucket)
 		return b.ForEach(func(k, v []byte) error {
 			var t Task
@@ -240,89 +543,229 @@ func GetTasks() ([]Task, error) {
 	return tasks, err
 }
 
-func ToggleTask(id string) error {
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *BoltStore) UpdateTask(id string, task Task) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tasksBucket)
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		var old Task
+		if err := json.Unmarshal(data, &old); err != nil {
+			return err
+		}
+		old.Name = task.Name
+		old.Description = task.Description
+		old.DueDate = task.DueDate
+
+		updatedData, err := json.Marshal(old)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(id), updatedData); err != nil {
+			return err
+		}
+		return appendEvent(tx, taskEvent(EventTaskUpdate, old), old)
+	})
+}
+
+func (s *BoltStore) ToggleTask(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(tasksBucket)
 		data := b.Get([]byte(id))
 		if data == nil {
 			return nil
 		}
-		
+
 		var task Task
 		if err := json.Unmarshal(data, &task); err != nil {
 			return err
 		}
-		
+
 		task.Completed = !task.Completed
-		
+
 		updatedData, err := json.Marshal(task)
 		if err != nil {
 			return err
 		}
-		
-		return b.Put([]byte(id), updatedData)
+
+		if err := b.Put([]byte(id), updatedData); err != nil {
+			return err
+		}
+		return appendEvent(tx, taskEvent(EventTaskToggle, task), task)
 	})
 }
 
-func DeleteTask(id string) error {
-	return db.Update(func(tx *bolt.Tx) error {
+func (s *BoltStore) DeleteTask(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(tasksBucket)
-		return b.Delete([]byte(id))
+		data := b.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return err
+		}
+		if err := b.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return appendEvent(tx, taskEvent(EventTaskDelete, task), nil)
 	})
 }
 
-func GetHabitStreak(habitID string) (int, error) {
-	today := time.Now()
-	streak := 0
-	
-	for i := 0; i < 365; i++ {
-		date := today.AddDate(0, 0, -i)
-		dateStr := date.Format("2006-01-02")
-		
-		completed, err := IsHabitCompleted(habitID, dateStr)
-		if err != nil {
-			return 0, err
-		}
-		
-		if completed {
-			streak++
-		} else {
-			break
+// The streaks bucket caches each habit's streakIndex. It is kept in step
+// with the completions in the same transaction that toggles them.
+
+func loadStreakIndex(tx *bolt.Tx, habitID string) (*streakIndex, error) {
+	index := &streakIndex{}
+	v := tx.Bucket(streaksBucket).Get([]byte(habitID))
+	if v == nil {
+		return index, nil
+	}
+	if err := json.Unmarshal(v, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func saveStreakIndex(tx *bolt.Tx, habitID string, index *streakIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(streaksBucket).Put([]byte(habitID), data)
+}
+
+func rebuildStreakIndex(tx *bolt.Tx, habit Habit, weekStart time.Weekday) error {
+	var completions []HabitCompletion
+	if b := tx.Bucket(completionsBucket).Bucket([]byte(habit.ID)); b != nil {
+		var err error
+		if completions, err = completionsIn(b, "", "9999-12-31"); err != nil {
+			return err
 		}
 	}
-	
-	return streak, nil
+	index, err := buildStreakIndex(habit, completions, weekStart)
+	if err != nil {
+		return fmt.Errorf("index %s: %w", habit.ID, err)
+	}
+	return saveStreakIndex(tx, habit.ID, index)
 }
 
-func GetHabitLongestStreak(habitID string) (int, error) {
-	longestStreak := 0
-	currentStreak := 0
-	
-	today := time.Now()
-	for i := 365; i >= 0; i-- {
-		date := today.AddDate(0, 0, -i)
-		dateStr := date.Format("2006-01-02")
-		
-		completed, err := IsHabitCompleted(habitID, dateStr)
+func (s *BoltStore) GetHabitStats(habitID string) (StreakStats, error) {
+	var stats StreakStats
+	err := s.db.View(func(tx *bolt.Tx) error {
+		habit, err := loadHabit(tx, habitID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		index, err := loadStreakIndex(tx, habitID)
 		if err != nil {
-			return 0, err
+			return err
 		}
-		
-		if completed {
-			currentStreak++
-			if currentStreak > longestStreak {
-				longestStreak = currentStreak
+		stats = index.stats(habit, s.clock.todayNumber(), s.clock.WeekStart)
+		return nil
+	})
+	return stats, err
+}
+
+func (s *BoltStore) GetHabitStreak(habitID string) (int, error) {
+	stats, err := s.GetHabitStats(habitID)
+	return stats.Current, err
+}
+
+func (s *BoltStore) GetHabitLongestStreak(habitID string) (int, error) {
+	stats, err := s.GetHabitStats(habitID)
+	return stats.Longest, err
+}
+
+func (s *BoltStore) Clock() Clock {
+	return s.clock
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+var (
+	undoKey = []byte("undo")
+	redoKey = []byte("redo")
+)
+
+func (s *BoltStore) GetHistory() (undo, redo []Operation, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		for _, stack := range []struct {
+			key []byte
+			ops *[]Operation
+		}{{undoKey, &undo}, {redoKey, &redo}} {
+			if v := b.Get(stack.key); v != nil {
+				if err := json.Unmarshal(v, stack.ops); err != nil {
+					return fmt.Errorf("%s history: %w", stack.key, err)
+				}
 			}
-		} else {
-			currentStreak = 0
 		}
+		return nil
+	})
+	return undo, redo, err
+}
+
+func (s *BoltStore) SaveHistory(undo, redo []Operation) error {
+	undoData, err := json.Marshal(undo)
+	if err != nil {
+		return err
 	}
-	
-	return longestStreak, nil
+	redoData, err := json.Marshal(redo)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		if err := b.Put(undoKey, undoData); err != nil {
+			return err
+		}
+		return b.Put(redoKey, redoData)
+	})
 }
 
-func CloseDB() {
-	if db != nil {
-		db.Close()
+// appendEvent logs e with record as its payload. Events are keyed by their
+// big-endian sequence number, so cursor order is the order they happened in.
+func appendEvent(tx *bolt.Tx, e Event, record any) error {
+	e, err := e.stamp(record)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(eventsBucket)
+	if e.Seq, err = b.NextSequence(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
 	}
+	return b.Put(binary.BigEndian.AppendUint64(nil, e.Seq), data)
+}
+
+// Events walks the log backwards from its last key, so a small limit or a
+// recent since reads only the end of it.
+func (s *BoltStore) Events(habitID string, since time.Time, limit int) ([]Event, error) {
+	var events []Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(events) < limit); k, v = c.Prev() {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !since.IsZero() && e.before(since) {
+				break // the log is in order, so the rest are older
+			}
+			if habitID == "" || e.HabitID == habitID {
+				events = append(events, e)
+			}
+		}
+		return nil
+	})
+	return events, err
 }
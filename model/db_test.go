@@ -1,178 +1,75 @@
 package model
 
 import (
-	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-func setupTestDB(t *testing.T) func() {
-	f, err := os.CreateTemp("", "tracker_test_*.db")
+func setupTestStore(t *testing.T) *BoltStore {
+	s, err := OpenBoltStore(filepath.Join(t.TempDir(), "tracker_test.db"), Clock{})
 	if err != nil {
-		t.Fatalf("create temp db: %v", err)
-	}
-	f.Close()
-	if err := InitDB(f.Name()); err != nil {
-		os.Remove(f.Name())
-		t.Fatalf("init db: %v", err)
-	}
-	return func() {
-		CloseDB()
-		os.Remove(f.Name())
+		t.Fatalf("open store: %v", err)
 	}
+	t.Cleanup(func() { s.Close() })
+	return s
 }
 
-func TestAddAndGetHabits(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
+func TestStoresAreIndependent(t *testing.T) {
+	a := setupTestStore(t)
+	b := setupTestStore(t)
 
-	if err := AddHabit("1", "drink water", "general", nil); err != nil {
-		t.Fatalf("AddHabit err: %v", err)
-	}
-
-	habits, err := GetHabits()
+	a.AddHabit(Habit{ID: "1", Name: "stretch", Type: "general"})
+	habits, err := b.GetHabits()
 	if err != nil {
 		t.Fatalf("GetHabits err: %v", err)
 	}
-	if len(habits) != 1 || habits[0].Name != "drink water" {
-		t.Fatalf("unexpected habits: %+v", habits)
-	}
-}
-
-func TestToggleHabitCompletion(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	AddHabit("1", "exercise", "general", nil)
-	date := time.Now().Format("2006-01-02")
-	if err := ToggleHabitCompletion("1", date); err != nil {
-		t.Fatalf("toggle: %v", err)
-	}
-	done, err := IsHabitCompleted("1", date)
-	if err != nil || !done {
-		t.Fatalf("completion not recorded")
-	}
-}
-
-func TestDeleteHabit(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	AddHabit("1", "read", "general", nil)
-	if err := DeleteHabit("1"); err != nil {
-		t.Fatalf("delete: %v", err)
-	}
-	habits, _ := GetHabits()
 	if len(habits) != 0 {
-		t.Fatalf("habit not deleted")
+		t.Fatalf("habit leaked into second store: %+v", habits)
 	}
 }
 
-func TestAddAndToggleTask(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	if err := AddTask("1", "task", "", ""); err != nil {
-		t.Fatalf("add task: %v", err)
-	}
-	if err := ToggleTask("1"); err != nil {
-		t.Fatalf("toggle task: %v", err)
-	}
-	tasks, err := GetTasks()
+func TestBoltStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracker_test.db")
+	s, err := OpenBoltStore(path, Clock{})
 	if err != nil {
-		t.Fatalf("get tasks: %v", err)
+		t.Fatalf("open store: %v", err)
 	}
-	if len(tasks) != 1 || !tasks[0].Completed {
-		t.Fatalf("task not toggled")
-	}
-}
+	s.AddHabit(Habit{ID: "1", Name: "stretch", Type: "general"})
+	s.Close()
 
-func TestDeleteTask(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	AddTask("1", "task", "", "")
-	if err := DeleteTask("1"); err != nil {
-		t.Fatalf("delete task: %v", err)
-	}
-	tasks, _ := GetTasks()
-	if len(tasks) != 0 {
-		t.Fatalf("task not deleted")
-	}
-}
-
-func TestUpdateHabit(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	AddHabit("1", "meditate", "daily", nil)
-	updatedHabit := Habit{
-		ID:    "1",
-		Name:  "meditate daily",
-		Type:  "daily",
-		Notes: map[string]string{"Monday": "10 minutes"},
-	}
-	if err := UpdateHabit("1", updatedHabit); err != nil {
-		t.Fatalf("update habit: %v", err)
+	s, err = OpenBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
 	}
-	habits, _ := GetHabits()
-	if len(habits) != 1 || habits[0].Name != "meditate daily" || habits[0].Notes["Monday"] != "10 minutes" {
-		t.Fatalf("habit not updated: %+v", habits[0])
+	defer s.Close()
+	habits, _ := s.GetHabits()
+	if len(habits) != 1 || habits[0].Name != "stretch" {
+		t.Fatalf("habit not persisted: %+v", habits)
 	}
 }
 
-func TestGetHabitStreak(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
-
-	AddHabit("1", "walk", "general", nil)
-	today := time.Now()
-	ToggleHabitCompletion("1", today.Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -1).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -2).Format("2006-01-02"))
-
-	streak, err := GetHabitStreak("1")
+func TestWeekStartChangeReindexes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracker_test.db")
+	s, err := OpenBoltStore(path, Clock{})
 	if err != nil {
-		t.Fatalf("get streak: %v", err)
-	}
-	if streak != 3 {
-		t.Fatalf("expected streak 3, got %d", streak)
+		t.Fatalf("open store: %v", err)
 	}
-
-	// test with a break in the streak
-	AddHabit("2", "run", "general", nil)
-	ToggleHabitCompletion("2", today.Format("2006-01-02"))
-	ToggleHabitCompletion("2", today.AddDate(0, 0, -2).Format("2006-01-02"))
-	streak, _ = GetHabitStreak("2")
-	if streak != 1 {
-		t.Fatalf("expected streak 1 after a break, got %d", streak)
+	// Twice a week; 2024-03-02 is a Saturday and 2024-03-03 a Sunday.
+	s.AddHabit(Habit{ID: "1", Name: "gym", Schedule: Schedule{Kind: ScheduleWeekly, Times: 2}})
+	s.ToggleHabitCompletion("1", "2024-03-02")
+	s.ToggleHabitCompletion("1", "2024-03-03")
+	if stats, _ := s.GetHabitStats("1"); stats.Longest != 0 {
+		t.Fatalf("Sunday-start weeks split the two days, got %+v", stats)
 	}
-}
-
-func TestGetHabitLongestStreak(t *testing.T) {
-	teardown := setupTestDB(t)
-	defer teardown()
+	s.Close()
 
-	AddHabit("1", "code", "general", nil)
-	today := time.Now()
-
-	// 5 day streak
-	ToggleHabitCompletion("1", today.Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -1).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -2).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -3).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -4).Format("2006-01-02"))
-
-	// 3 day streak
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -6).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -7).Format("2006-01-02"))
-	ToggleHabitCompletion("1", today.AddDate(0, 0, -8).Format("2006-01-02"))
-
-	longest, err := GetHabitLongestStreak("1")
+	s, err = OpenBoltStore(path, Clock{WeekStart: time.Monday})
 	if err != nil {
-		t.Fatalf("get longest streak: %v", err)
+		t.Fatalf("reopen store: %v", err)
 	}
-	if longest != 5 {
-		t.Fatalf("expected longest streak 5, got %d", longest)
+	defer s.Close()
+	if stats, _ := s.GetHabitStats("1"); stats.Longest != 1 || stats.LongestStart != "2024-02-26" {
+		t.Fatalf("expected one Monday-start week met after reopening, got %+v", stats)
 	}
-}
\ No newline at end of file
+}
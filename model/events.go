@@ -0,0 +1,67 @@
+// File: model/events.go
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is one entry in the append-only log of every change a Store has
+// made. Nothing ever rewrites or removes one, so the log still answers
+// "when did I check this off" after the change has been undone.
+type Event struct {
+	Seq     uint64          `json:"seq"`
+	At      string          `json:"at"` // RFC 3339, in UTC
+	Action  string          `json:"action"`
+	HabitID string          `json:"habit_id,omitempty"`
+	TaskID  string          `json:"task_id,omitempty"`
+	Name    string          `json:"name,omitempty"` // the habit's or task's, when the event happened
+	Date    string          `json:"date,omitempty"` // the day a completion or note is for
+	Payload json.RawMessage `json:"payload"`        // the record afterwards; null once it's gone
+}
+
+// The actions an Event records.
+const (
+	EventHabitCreate    = "habit.create"
+	EventHabitUpdate    = "habit.update"
+	EventHabitArchive   = "habit.archive"
+	EventHabitUnarchive = "habit.unarchive"
+	EventHabitDelete    = "habit.delete"
+	EventHabitRestore   = "habit.restore"
+	EventHabitPurge     = "habit.purge"
+
+	EventCompletionToggle = "completion.toggle"
+	EventCompletionValue  = "completion.value"
+	EventNoteSet          = "note.set"
+
+	EventTaskCreate = "task.create"
+	EventTaskUpdate = "task.update"
+	EventTaskToggle = "task.toggle"
+	EventTaskDelete = "task.delete"
+)
+
+func habitEvent(action string, h Habit) Event {
+	return Event{Action: action, HabitID: h.ID, Name: h.Name}
+}
+
+func taskEvent(action string, t Task) Event {
+	return Event{Action: action, TaskID: t.ID, Name: t.Name}
+}
+
+// before reports whether e happened before since.
+func (e Event) before(since time.Time) bool {
+	at, err := time.Parse(time.RFC3339, e.At)
+	return err == nil && at.Before(since)
+}
+
+// stamp fills in when e happened and what the record became; pass a nil
+// pointer for a record that's gone.
+func (e Event) stamp(record any) (Event, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return e, err
+	}
+	e.At = now().UTC().Format(time.RFC3339)
+	e.Payload = payload
+	return e, nil
+}
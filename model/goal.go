@@ -0,0 +1,162 @@
+// File: model/goal.go
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	AggregateSum  = "sum"
+	AggregateMax  = "max"
+	AggregateLast = "last"
+)
+
+// Goal turns a habit into a quantitative one: instead of done/not done,
+// each day records a value, and the habit counts as done once the value
+// reaches Target. Per is "day" or "week"; a weekly goal is judged on the
+// week's values combined with Aggregation.
+type Goal struct {
+	Unit        string  `json:"unit"`
+	Target      float64 `json:"target"`
+	Per         string  `json:"per"`
+	Aggregation string  `json:"aggregation"`
+}
+
+func (g *Goal) Validate() error {
+	if g == nil {
+		return nil
+	}
+	if !(g.Target > 0) || math.IsInf(g.Target, 0) {
+		return fmt.Errorf("goal target must be a positive number")
+	}
+	if g.Per != "day" && g.Per != "week" {
+		return fmt.Errorf("goal must be per day or per week, not %q", g.Per)
+	}
+	switch g.Aggregation {
+	case AggregateSum, AggregateMax, AggregateLast:
+	default:
+		return fmt.Errorf("unknown aggregation %q", g.Aggregation)
+	}
+	return nil
+}
+
+// ValidateValue rejects a day's value that isn't a finite number, which
+// couldn't be stored.
+func ValidateValue(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("value %v is not a number", v)
+	}
+	return nil
+}
+
+func (h Habit) validate() error {
+	if err := h.Schedule.Validate(); err != nil {
+		return err
+	}
+	return h.Goal.Validate()
+}
+
+func (g *Goal) Equal(o *Goal) bool {
+	if g == nil || o == nil {
+		return g == o
+	}
+	return *g == *o
+}
+
+// Combine folds a newly entered value into what the day already has.
+func (g *Goal) Combine(current, entry float64) float64 {
+	switch g.Aggregation {
+	case AggregateMax:
+		return max(current, entry)
+	case AggregateLast:
+		return entry
+	default:
+		return current + entry
+	}
+}
+
+// Aggregate combines the daily values of completions, oldest first.
+func (g *Goal) Aggregate(completions []HabitCompletion) float64 {
+	total := 0.0
+	switch g.Aggregation {
+	case AggregateMax:
+		for _, c := range completions {
+			total = max(total, c.Value)
+		}
+	case AggregateLast:
+		if len(completions) > 0 {
+			total = completions[len(completions)-1].Value
+		}
+	default:
+		for _, c := range completions {
+			total += c.Value
+		}
+	}
+	return total
+}
+
+// Progress is value as a fraction of the target, capped at 1.
+func (g *Goal) Progress(value float64) float64 {
+	return min(value/g.Target, 1)
+}
+
+func (g *Goal) String() string {
+	if g == nil {
+		return ""
+	}
+	s := FormatValue(g.Target)
+	if g.Unit != "" {
+		s += " " + g.Unit
+	}
+	s += "/" + g.Per
+	if g.Aggregation != AggregateSum {
+		s += " " + g.Aggregation
+	}
+	return s
+}
+
+// FormatValue prints v without trailing zeros.
+func FormatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// ParseGoal reads "<target> [unit]/<day|week> [sum|max|last]", for example
+// "8 glasses/day" or "100 pushups/day max". An empty string means no goal.
+func ParseGoal(text string) (*Goal, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("invalid goal %q: want e.g. \"8 glasses/day\" or \"20 km/week\"", text)
+
+	g := &Goal{Aggregation: AggregateSum}
+	fields := strings.Fields(text)
+	switch agg := fields[len(fields)-1]; agg {
+	case AggregateSum, AggregateMax, AggregateLast:
+		g.Aggregation = agg
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return nil, invalid
+	}
+	rest := strings.Join(fields, " ")
+	slash := strings.LastIndex(rest, "/")
+	if slash < 0 {
+		return nil, invalid
+	}
+	g.Per = strings.TrimSpace(rest[slash+1:])
+	amount := strings.Fields(rest[:slash])
+	if len(amount) == 0 {
+		return nil, invalid
+	}
+	target, err := strconv.ParseFloat(amount[0], 64)
+	if err != nil {
+		return nil, invalid
+	}
+	g.Target = target
+	g.Unit = strings.Join(amount[1:], " ")
+	return g, g.Validate()
+}
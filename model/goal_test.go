@@ -0,0 +1,46 @@
+package model
+
+import "testing"
+
+func TestParseGoalRoundTrip(t *testing.T) {
+	for _, text := range []string{"8 glasses/day", "20 km/week", "100 pushups/day max", "72.5 kg/week last", "3/day"} {
+		g, err := ParseGoal(text)
+		if err != nil {
+			t.Fatalf("parse %q: %v", text, err)
+		}
+		if g.String() != text {
+			t.Fatalf("expected %q, got %q", text, g.String())
+		}
+	}
+
+	if g, err := ParseGoal("  "); g != nil || err != nil {
+		t.Fatalf("expected no goal for blank input, got %+v, %v", g, err)
+	}
+	for _, text := range []string{"glasses/day", "8 glasses", "8 glasses/month", "0 km/week", "sum", "NaN glasses/day", "Inf km/week"} {
+		if _, err := ParseGoal(text); err == nil {
+			t.Fatalf("expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestGoalCombineAndAggregate(t *testing.T) {
+	days := []HabitCompletion{{Value: 3}, {Value: 7}, {Value: 5}}
+	cases := []struct {
+		agg       string
+		combined  float64
+		aggregate float64
+	}{
+		{AggregateSum, 9, 15},
+		{AggregateMax, 5, 7},
+		{AggregateLast, 4, 5},
+	}
+	for _, c := range cases {
+		g := &Goal{Target: 10, Per: "day", Aggregation: c.agg}
+		if got := g.Combine(5, 4); got != c.combined {
+			t.Fatalf("%s: Combine(5, 4) = %v, want %v", c.agg, got, c.combined)
+		}
+		if got := g.Aggregate(days); got != c.aggregate {
+			t.Fatalf("%s: Aggregate = %v, want %v", c.agg, got, c.aggregate)
+		}
+	}
+}
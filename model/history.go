@@ -0,0 +1,473 @@
+// File: model/history.go
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrHistoryStale means the record an operation changed has been
+	// changed again since, outside this history, so walking back over the
+	// operation would overwrite that change.
+	ErrHistoryStale = errors.New("changed elsewhere since, so it was dropped from the history")
+)
+
+// HistoryLimit is how many operations History keeps to undo.
+const HistoryLimit = 100
+
+// Change is the state of one record before and after an operation; nil
+// means the record didn't exist.
+type Change[T any] struct {
+	Before *T `json:"before"`
+	After  *T `json:"after"`
+}
+
+// Operation is one reversible change made through History. Exactly one of
+// the changes is set. A note that doesn't exist is one with empty Text.
+type Operation struct {
+	Summary    string                   `json:"summary"` // e.g. `archived "read"`
+	At         string                   `json:"at"`      // RFC 3339
+	Habit      *Change[Habit]           `json:"habit,omitempty"`
+	Completion *Change[HabitCompletion] `json:"completion,omitempty"`
+	Note       *Change[DayNote]         `json:"note,omitempty"`
+	Task       *Change[Task]            `json:"task,omitempty"`
+}
+
+// History is a Store that records every change made through it, so Undo
+// and Redo can walk back and forth over them. Reads and changes made
+// straight to the wrapped Store aren't recorded, and an operation whose
+// record has been changed since is dropped rather than walked over.
+//
+// Purging can't be undone, so it clears the history.
+type History struct {
+	Store
+	undo, redo []Operation
+	persist    bool
+}
+
+// NewHistory records changes to store for as long as the History lives,
+// as the TUI does for a session.
+func NewHistory(store Store) *History {
+	return &History{Store: store}
+}
+
+// OpenHistory shares the history saved in store: it reads the saved stacks
+// before every undo, redo or change and saves them after, so the TUI and
+// separate command line runs walk the same history.
+func OpenHistory(store Store) (*History, error) {
+	h := &History{Store: store, persist: true}
+	return h, h.load()
+}
+
+// Undo reverses the latest operation and returns it. If its record has
+// changed since, the operation is dropped and ErrHistoryStale returned.
+func (h *History) Undo() (Operation, error) {
+	if err := h.load(); err != nil {
+		return Operation{}, err
+	}
+	if len(h.undo) == 0 {
+		return Operation{}, ErrNothingToUndo
+	}
+	op := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	if err := h.apply(op, true); errors.Is(err, ErrHistoryStale) {
+		return op, errors.Join(err, h.save())
+	} else if err != nil {
+		h.undo = append(h.undo, op)
+		return op, err
+	}
+	h.redo = append(h.redo, op)
+	return op, h.save()
+}
+
+// Redo repeats the latest undone operation and returns it. Like Undo, it
+// drops an operation whose record has changed since.
+func (h *History) Redo() (Operation, error) {
+	if err := h.load(); err != nil {
+		return Operation{}, err
+	}
+	if len(h.redo) == 0 {
+		return Operation{}, ErrNothingToRedo
+	}
+	op := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	if err := h.apply(op, false); errors.Is(err, ErrHistoryStale) {
+		return op, errors.Join(err, h.save())
+	} else if err != nil {
+		h.redo = append(h.redo, op)
+		return op, err
+	}
+	h.undo = append(h.undo, op)
+	return op, h.save()
+}
+
+// push records op, which a new change has just made, dropping anything
+// that could have been redone.
+func (h *History) push(op Operation) error {
+	if err := h.load(); err != nil {
+		return err
+	}
+	op.At = now().Format(time.RFC3339)
+	h.undo = append(h.undo, op)
+	if len(h.undo) > HistoryLimit {
+		h.undo = append([]Operation(nil), h.undo[len(h.undo)-HistoryLimit:]...)
+	}
+	h.redo = nil
+	return h.save()
+}
+
+// load reads the saved stacks, which another History sharing the store may
+// have changed.
+func (h *History) load() error {
+	if !h.persist {
+		return nil
+	}
+	var err error
+	h.undo, h.redo, err = h.Store.GetHistory()
+	return err
+}
+
+func (h *History) save() error {
+	if !h.persist {
+		return nil
+	}
+	return h.Store.SaveHistory(h.undo, h.redo)
+}
+
+// apply puts every record op changed back the way it was before it, or
+// the way op left it when undo is false. It returns ErrHistoryStale,
+// changing nothing, unless the record is still the way op's other side
+// expects.
+func (h *History) apply(op Operation, undo bool) error {
+	switch {
+	case op.Habit != nil:
+		current, err := h.habit(either(op.Habit).ID)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.DeletedAt != "" && op.Habit.state(!undo) == nil {
+			current = nil // undone adds are only hidden; see putHabit
+		}
+		if err := checkCurrent(op, current, op.Habit.state(!undo)); err != nil {
+			return err
+		}
+		return h.putHabit(op.Habit, current, op.Habit.state(undo))
+	case op.Completion != nil:
+		current, err := h.completion(either(op.Completion).HabitID, either(op.Completion).Date)
+		if err != nil {
+			return err
+		}
+		if err := checkCurrent(op, current, op.Completion.state(!undo)); err != nil {
+			return err
+		}
+		return h.putCompletion(op.Completion, current, op.Completion.state(undo))
+	case op.Note != nil:
+		n := op.Note.state(undo)
+		current, err := h.note(n.HabitID, n.Date)
+		if err != nil {
+			return err
+		}
+		if err := checkCurrent(op, current, op.Note.state(!undo)); err != nil {
+			return err
+		}
+		return h.Store.SetNote(n.HabitID, n.Date, n.Text)
+	case op.Task != nil:
+		current, err := h.task(either(op.Task).ID)
+		if err != nil {
+			return err
+		}
+		if err := checkCurrent(op, current, op.Task.state(!undo)); err != nil {
+			return err
+		}
+		return h.putTask(op.Task, current, op.Task.state(undo))
+	}
+	return fmt.Errorf("operation %q changes nothing", op.Summary)
+}
+
+// checkCurrent returns ErrHistoryStale unless the record op changed is
+// still as expected. Both are compared as JSON, the form saved histories
+// are kept in, without the timestamps the store sets itself: a redo or an
+// undone delete writes the record again and stamps it anew.
+func checkCurrent[T any](op Operation, current, expected *T) error {
+	a, err := json.Marshal(unstamped(current))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(unstamped(expected))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return fmt.Errorf("%s: %w", op.Summary, ErrHistoryStale)
+	}
+	return nil
+}
+
+// unstamped returns record with LoggedAt or CreatedAt cleared, for the
+// records whose stamp the store sets on every write.
+func unstamped[T any](record *T) any {
+	switch r := any(record).(type) {
+	case *HabitCompletion:
+		if r != nil {
+			c := *r
+			c.LoggedAt = ""
+			return &c
+		}
+	case *Task:
+		if r != nil {
+			t := *r
+			t.CreatedAt = ""
+			return &t
+		}
+	}
+	return record
+}
+
+func (h *History) putHabit(c *Change[Habit], current, want *Habit) error {
+	if want == nil {
+		// Undoing an add hides the habit like DeleteHabit rather than
+		// dropping it, so nothing recorded against it is lost.
+		return h.Store.DeleteHabit(either(c).ID)
+	}
+	if current == nil {
+		if deleted, err := h.habit(want.ID); err != nil {
+			return err
+		} else if deleted == nil {
+			return h.Store.AddHabit(*want)
+		}
+		// redoing an add that undo hid
+		if err := h.Store.RestoreHabit(want.ID); err != nil {
+			return err
+		}
+	}
+	return h.Store.UpdateHabit(want.ID, *want)
+}
+
+func (h *History) putCompletion(c *Change[HabitCompletion], current, want *HabitCompletion) error {
+	habitID, date := either(c).HabitID, either(c).Date
+	switch {
+	case want == nil && current == nil:
+		return nil
+	case want == nil && current.Value == 0:
+		return h.Store.ToggleHabitCompletion(habitID, date) // a habit without a goal
+	case want == nil:
+		return h.Store.SetHabitValue(habitID, date, 0)
+	case want.Value > 0:
+		return h.Store.SetHabitValue(habitID, date, want.Value)
+	case current == nil:
+		return h.Store.ToggleHabitCompletion(habitID, date)
+	}
+	return nil
+}
+
+func (h *History) putTask(c *Change[Task], current, want *Task) error {
+	if want == nil {
+		return h.Store.DeleteTask(either(c).ID)
+	}
+	if current == nil {
+		if err := h.Store.AddTask(want.ID, want.Name, want.Description, want.DueDate); err != nil {
+			return err
+		}
+		current = &Task{}
+	} else if err := h.Store.UpdateTask(want.ID, *want); err != nil {
+		return err
+	}
+	if current.Completed != want.Completed {
+		return h.Store.ToggleTask(want.ID)
+	}
+	return nil
+}
+
+// state is the record as it was before the change, or after it.
+func (c *Change[T]) state(before bool) *T {
+	if before {
+		return c.Before
+	}
+	return c.After
+}
+
+// either is whichever side of c exists.
+func either[T any](c *Change[T]) *T {
+	if c.Before != nil {
+		return c.Before
+	}
+	return c.After
+}
+
+// record makes change and pushes an operation holding what it did to the
+// record read by get, unless it did nothing.
+func record[T any](h *History, get func() (*T, error), change func() error, summary string, wrap func(*Change[T]) Operation) error {
+	before, err := get()
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := get()
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	op := wrap(&Change[T]{Before: before, After: after})
+	op.Summary = summary
+	return h.push(op)
+}
+
+func (h *History) changeHabit(id, summary string, change func() error) error {
+	return record(h, func() (*Habit, error) { return h.habit(id) }, change, summary,
+		func(c *Change[Habit]) Operation { return Operation{Habit: c} })
+}
+
+func (h *History) AddHabit(habit Habit) error {
+	return h.changeHabit(habit.ID, fmt.Sprintf("added %q", habit.Name), func() error { return h.Store.AddHabit(habit) })
+}
+
+func (h *History) UpdateHabit(id string, habit Habit) error {
+	return h.changeHabit(id, fmt.Sprintf("edited %q", habit.Name), func() error { return h.Store.UpdateHabit(id, habit) })
+}
+
+func (h *History) ArchiveHabit(id string) error {
+	return h.changeHabit(id, fmt.Sprintf("archived %q", h.habitName(id)), func() error { return h.Store.ArchiveHabit(id) })
+}
+
+func (h *History) UnarchiveHabit(id string) error {
+	return h.changeHabit(id, fmt.Sprintf("unarchived %q", h.habitName(id)), func() error { return h.Store.UnarchiveHabit(id) })
+}
+
+func (h *History) DeleteHabit(id string) error {
+	return h.changeHabit(id, fmt.Sprintf("deleted %q", h.habitName(id)), func() error { return h.Store.DeleteHabit(id) })
+}
+
+func (h *History) RestoreHabit(id string) error {
+	return h.changeHabit(id, fmt.Sprintf("restored %q", h.habitName(id)), func() error { return h.Store.RestoreHabit(id) })
+}
+
+func (h *History) PurgeDeletedHabits(cutoff time.Time) ([]Habit, error) {
+	purged, err := h.Store.PurgeDeletedHabits(cutoff)
+	if err != nil || len(purged) == 0 {
+		return purged, err
+	}
+	h.undo, h.redo = nil, nil
+	return purged, h.save()
+}
+
+func (h *History) DeleteHabitPermanently(id string) error {
+	if err := h.Store.DeleteHabitPermanently(id); err != nil {
+		return err
+	}
+	h.undo, h.redo = nil, nil
+	return h.save()
+}
+
+func (h *History) changeCompletion(habitID, date, summary string, change func() error) error {
+	return record(h, func() (*HabitCompletion, error) { return h.completion(habitID, date) }, change, summary,
+		func(c *Change[HabitCompletion]) Operation { return Operation{Completion: c} })
+}
+
+func (h *History) ToggleHabitCompletion(habitID, date string) error {
+	return h.changeCompletion(habitID, date, fmt.Sprintf("toggled %q on %s", h.habitName(habitID), date),
+		func() error { return h.Store.ToggleHabitCompletion(habitID, date) })
+}
+
+func (h *History) SetHabitValue(habitID, date string, value float64) error {
+	return h.changeCompletion(habitID, date, fmt.Sprintf("set %q to %s on %s", h.habitName(habitID), FormatValue(value), date),
+		func() error { return h.Store.SetHabitValue(habitID, date, value) })
+}
+
+func (h *History) SetNote(habitID, date, text string) error {
+	get := func() (*DayNote, error) { return h.note(habitID, date) }
+	return record(h, get, func() error { return h.Store.SetNote(habitID, date, text) },
+		fmt.Sprintf("changed the note on %q for %s", h.habitName(habitID), date),
+		func(c *Change[DayNote]) Operation { return Operation{Note: c} })
+}
+
+func (h *History) changeTask(id, summary string, change func() error) error {
+	return record(h, func() (*Task, error) { return h.task(id) }, change, summary,
+		func(c *Change[Task]) Operation { return Operation{Task: c} })
+}
+
+func (h *History) AddTask(id, name, description, dueDate string) error {
+	return h.changeTask(id, fmt.Sprintf("added task %q", name), func() error { return h.Store.AddTask(id, name, description, dueDate) })
+}
+
+func (h *History) UpdateTask(id string, task Task) error {
+	return h.changeTask(id, fmt.Sprintf("edited task %q", task.Name), func() error { return h.Store.UpdateTask(id, task) })
+}
+
+func (h *History) ToggleTask(id string) error {
+	return h.changeTask(id, fmt.Sprintf("toggled task %q", h.taskName(id)), func() error { return h.Store.ToggleTask(id) })
+}
+
+func (h *History) DeleteTask(id string) error {
+	return h.changeTask(id, fmt.Sprintf("deleted task %q", h.taskName(id)), func() error { return h.Store.DeleteTask(id) })
+}
+
+// habit finds the habit with id wherever it is listed, or returns nil.
+func (h *History) habit(id string) (*Habit, error) {
+	for _, list := range []func() ([]Habit, error){h.Store.GetHabits, h.Store.GetArchivedHabits, h.Store.GetDeletedHabits} {
+		habits, err := list()
+		if err != nil {
+			return nil, err
+		}
+		for i := range habits {
+			if habits[i].ID == id {
+				return &habits[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (h *History) habitName(id string) string {
+	if habit, _ := h.habit(id); habit != nil {
+		return habit.Name
+	}
+	return id
+}
+
+func (h *History) completion(habitID, date string) (*HabitCompletion, error) {
+	completions, err := h.Store.CompletionsBetween(habitID, date, date)
+	if err != nil || len(completions) == 0 {
+		return nil, err
+	}
+	return &completions[0], nil
+}
+
+// note returns the habit's note for date, with empty Text if there is none.
+func (h *History) note(habitID, date string) (*DayNote, error) {
+	notes, err := h.Store.NotesBetween(habitID, date, date)
+	if err != nil || len(notes) == 0 {
+		return &DayNote{HabitID: habitID, Date: date}, err
+	}
+	return &notes[0], nil
+}
+
+func (h *History) task(id string) (*Task, error) {
+	tasks, err := h.Store.GetTasks()
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		if tasks[i].ID == id {
+			return &tasks[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *History) taskName(id string) string {
+	if t, _ := h.task(id); t != nil {
+		return t.Name
+	}
+	return id
+}
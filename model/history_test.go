@@ -0,0 +1,255 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistoryUndoesAndRedoesEveryChange(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"bolt":   func(t *testing.T) Store { return setupTestStore(t) },
+		"memory": func(*testing.T) Store { return NewMemoryStore(Clock{}) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			h := NewHistory(s)
+			water, _ := ParseGoal("8 glasses/day")
+
+			steps := []struct {
+				change func() error
+				check  func() bool // true once the change has been made
+			}{
+				{func() error { return h.AddHabit(Habit{ID: "1", Name: "read"}) }, func() bool {
+					habits, _ := s.GetHabits()
+					return len(habits) == 1
+				}},
+				{func() error { return h.AddHabit(Habit{ID: "2", Name: "water", Goal: water}) }, func() bool {
+					habits, _ := s.GetHabits()
+					return len(habits) == 2
+				}},
+				{func() error { return h.ToggleHabitCompletion("1", "2024-03-01") }, func() bool {
+					done, _ := s.IsHabitCompleted("1", "2024-03-01")
+					return done
+				}},
+				{func() error { return h.SetHabitValue("2", "2024-03-01", 3) }, func() bool {
+					c, _ := s.CompletionsBetween("2", "2024-03-01", "2024-03-01")
+					return len(c) == 1 && c[0].Value == 3
+				}},
+				{func() error { return h.SetNote("1", "2024-03-01", "rainy") }, func() bool {
+					notes, _ := s.NotesBetween("1", "2024-03-01", "2024-03-01")
+					return len(notes) == 1
+				}},
+				{func() error { return h.UpdateHabit("1", Habit{ID: "1", Name: "read more"}) }, func() bool {
+					habits, _ := s.GetHabits()
+					return len(habits) > 0 && habits[0].Name == "read more"
+				}},
+				{func() error { return h.ArchiveHabit("1") }, func() bool {
+					archived, _ := s.GetArchivedHabits()
+					return len(archived) == 1
+				}},
+				{func() error { return h.DeleteHabit("1") }, func() bool {
+					deleted, _ := s.GetDeletedHabits()
+					return len(deleted) == 1
+				}},
+				{func() error { return h.AddTask("t", "taxes", "", "2024-04-15") }, func() bool {
+					tasks, _ := s.GetTasks()
+					return len(tasks) == 1
+				}},
+				{func() error { return h.ToggleTask("t") }, func() bool {
+					tasks, _ := s.GetTasks()
+					return len(tasks) == 1 && tasks[0].Completed
+				}},
+				{func() error { return h.DeleteTask("t") }, func() bool {
+					tasks, _ := s.GetTasks()
+					return len(tasks) == 0
+				}},
+			}
+			for i, step := range steps {
+				if err := step.change(); err != nil || !step.check() {
+					t.Fatalf("step %d: change not made (%v)", i, err)
+				}
+			}
+
+			for i := len(steps) - 1; i >= 0; i-- {
+				op, err := h.Undo()
+				if err != nil || steps[i].check() {
+					t.Fatalf("undoing step %d (%s) left it in place (%v)", i, op.Summary, err)
+				}
+			}
+			if _, err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+				t.Fatalf("expected nothing left to undo, got %v", err)
+			}
+			if habits, _ := s.GetHabits(); len(habits) != 0 {
+				t.Fatalf("expected undoing the adds to remove the habits, got %+v", habits)
+			}
+
+			for i := range steps {
+				op, err := h.Redo()
+				if err != nil || !steps[i].check() {
+					t.Fatalf("redoing step %d (%s) didn't make it again (%v)", i, op.Summary, err)
+				}
+			}
+			if _, err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+				t.Fatalf("expected nothing left to redo, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHistoryNewChangeDropsRedo(t *testing.T) {
+	h := NewHistory(NewMemoryStore(Clock{}))
+	h.AddHabit(Habit{ID: "1", Name: "read"})
+	h.ToggleHabitCompletion("1", "2024-03-01")
+	h.ToggleHabitCompletion("1", "2024-03-01") // a second toggle is a change of its own
+
+	op, _ := h.Undo()
+	if op.Summary != `toggled "read" on 2024-03-01` {
+		t.Fatalf("unexpected summary %q", op.Summary)
+	}
+	h.SetNote("1", "2024-03-01", "")  // no change, not recorded
+	h.SetNote("1", "2024-03-02", "x") // a new change
+	if _, err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("expected a new change to drop the redo stack, got %v", err)
+	}
+	if op, _ := h.Undo(); op.Note == nil {
+		t.Fatalf("expected the note change to be undone first, got %q", op.Summary)
+	}
+}
+
+func TestOpenHistorySharesHistoryBetweenRuns(t *testing.T) {
+	s := setupTestStore(t)
+	first, err := OpenHistory(s)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	first.AddHabit(Habit{ID: "1", Name: "read"})
+	first.ToggleHabitCompletion("1", "2024-03-01")
+
+	second, err := OpenHistory(s)
+	if err != nil {
+		t.Fatalf("reopen history: %v", err)
+	}
+	if _, err := second.Undo(); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if done, _ := s.IsHabitCompleted("1", "2024-03-01"); done {
+		t.Fatal("expected a later run to undo the toggle")
+	}
+
+	s.DeleteHabit("1")
+	third, _ := OpenHistory(s)
+	if _, err := third.PurgeDeletedHabits(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := third.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("expected purging to clear the history, got %v", err)
+	}
+}
+
+func TestOpenHistoryRefusesStaleOperations(t *testing.T) {
+	s := setupTestStore(t)
+	cli, _ := OpenHistory(s)
+	tui, _ := OpenHistory(s)
+	cli.AddHabit(Habit{ID: "1", Name: "read"})
+	tui.ToggleHabitCompletion("1", "2024-03-01")
+
+	if op, err := cli.Undo(); err != nil || op.Completion == nil {
+		t.Fatalf("expected the toggle made through the other history to be undone first, got %q (%v)", op.Summary, err)
+	}
+	tui.ToggleHabitCompletion("1", "2024-03-02")
+	s.UpdateHabit("1", Habit{ID: "1", Name: "read more"}) // not recorded
+	if _, err := cli.Undo(); err != nil {
+		t.Fatalf("undo toggle: %v", err)
+	}
+	if _, err := cli.Undo(); !errors.Is(err, ErrHistoryStale) {
+		t.Fatalf("expected undoing the add of a since-edited habit to be refused, got %v", err)
+	}
+	if habits, _ := s.GetHabits(); len(habits) != 1 || habits[0].Name != "read more" {
+		t.Fatalf("expected the edited habit to stay, got %+v", habits)
+	}
+	if _, err := tui.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("expected the stale operation to be dropped, got %v", err)
+	}
+}
+
+func TestUndoingAnAddKeepsHistory(t *testing.T) {
+	s := NewMemoryStore(Clock{})
+	h := NewHistory(s)
+	h.AddHabit(Habit{ID: "1", Name: "read"})
+	s.ToggleHabitCompletion("1", "2024-03-01") // not recorded
+
+	if _, err := h.Undo(); err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if deleted, _ := s.GetDeletedHabits(); len(deleted) != 1 {
+		t.Fatalf("expected the habit to be hidden, not dropped, got %+v", deleted)
+	}
+	if _, err := h.Redo(); err != nil {
+		t.Fatalf("redo: %v", err)
+	}
+	if habits, _ := s.GetHabits(); len(habits) != 1 {
+		t.Fatalf("expected redo to bring the habit back, got %+v", habits)
+	}
+	if done, _ := s.IsHabitCompleted("1", "2024-03-01"); !done {
+		t.Fatal("expected the completion to survive the undo")
+	}
+}
+
+func TestHistoryWalksBackAndForthAsTimePasses(t *testing.T) {
+	t.Cleanup(func() { now = time.Now })
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		at = at.Add(time.Minute)
+		return at
+	}
+	stores := map[string]func(t *testing.T) Store{
+		"bolt":   func(t *testing.T) Store { return setupTestStore(t) },
+		"memory": func(*testing.T) Store { return NewMemoryStore(Clock{}) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			h, err := OpenHistory(s)
+			if err != nil {
+				t.Fatalf("open history: %v", err)
+			}
+			water, _ := ParseGoal("8 glasses/day")
+			h.AddHabit(Habit{ID: "1", Name: "read"})
+			h.AddHabit(Habit{ID: "2", Name: "water", Goal: water})
+			h.AddTask("t", "taxes", "", "")
+			h.ToggleHabitCompletion("1", "2024-03-01")
+			h.SetHabitValue("2", "2024-03-01", 3)
+			h.DeleteTask("t")
+
+			// every step rewrites a record, and the clock moves on each time
+			for i := 0; i < 3; i++ {
+				for n := 0; n < 3; n++ {
+					if op, err := h.Undo(); err != nil {
+						t.Fatalf("round %d: undo %q: %v", i, op.Summary, err)
+					}
+				}
+				for n := 0; n < 3; n++ {
+					if op, err := h.Redo(); err != nil {
+						t.Fatalf("round %d: redo %q: %v", i, op.Summary, err)
+					}
+				}
+			}
+			if tasks, _ := s.GetTasks(); len(tasks) != 0 {
+				t.Fatalf("expected the task deleted again, got %+v", tasks)
+			}
+			if value := dayValueOf(s, "2", "2024-03-01"); value != 3 {
+				t.Fatalf("expected the value back at 3, got %v", value)
+			}
+		})
+	}
+}
+
+func dayValueOf(s Store, habitID, date string) float64 {
+	completions, _ := s.CompletionsBetween(habitID, date, date)
+	if len(completions) == 0 {
+		return 0
+	}
+	return completions[0].Value
+}
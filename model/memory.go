@@ -0,0 +1,448 @@
+// File: model/memory.go
+package model
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in process memory. Nothing is
+// written to disk, which makes it suitable for tests and ephemeral sessions.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	habits      map[string]Habit
+	completions map[string]map[string]HabitCompletion // habit ID -> date
+	tasks       map[string]Task
+	streaks     map[string]*streakIndex
+	notes       map[string]map[string]DayNote // habit ID -> date
+	undo, redo  []Operation
+	events      []Event
+	clock       Clock
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty store whose days are decided by clock.
+func NewMemoryStore(clock Clock) *MemoryStore {
+	return &MemoryStore{
+		clock:       clock,
+		habits:      make(map[string]Habit),
+		completions: make(map[string]map[string]HabitCompletion),
+		tasks:       make(map[string]Task),
+		streaks:     make(map[string]*streakIndex),
+		notes:       make(map[string]map[string]DayNote),
+	}
+}
+
+// cloneHabit copies h so callers can't mutate the stored notes, goal or
+// weekdays, matching the bbolt store which decodes a fresh value on every
+// read.
+func cloneHabit(h Habit) Habit {
+	h.Schedule.Weekdays = slices.Clone(h.Schedule.Weekdays)
+	if h.Goal != nil {
+		goal := *h.Goal
+		h.Goal = &goal
+	}
+	if h.Notes != nil {
+		notes := make(map[string]string, len(h.Notes))
+		for k, v := range h.Notes {
+			notes[k] = v
+		}
+		h.Notes = notes
+	}
+	return h
+}
+
+func (s *MemoryStore) habitsWhere(keep func(Habit) bool) []Habit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var habits []Habit
+	for _, h := range s.habits {
+		if keep(h) {
+			habits = append(habits, cloneHabit(h))
+		}
+	}
+	sort.Slice(habits, func(i, j int) bool { return habits[i].ID < habits[j].ID })
+	return habits
+}
+
+func (s *MemoryStore) GetHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return !h.Archived && h.DeletedAt == "" }), nil
+}
+
+func (s *MemoryStore) GetArchivedHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return h.Archived && h.DeletedAt == "" }), nil
+}
+
+func (s *MemoryStore) GetDeletedHabits() ([]Habit, error) {
+	return s.habitsWhere(func(h Habit) bool { return h.DeletedAt != "" }), nil
+}
+
+func (s *MemoryStore) AddHabit(habit Habit) error {
+	if err := habit.validate(); err != nil {
+		return err
+	}
+	if habit.CreatedAt == "" {
+		habit.CreatedAt = s.clock.Today().Format("2006-01-02")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.habits[habit.ID] = cloneHabit(habit)
+	return s.logEvent(habitEvent(EventHabitCreate, habit), habit)
+}
+
+func (s *MemoryStore) UpdateHabit(id string, habit Habit) error {
+	if err := habit.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.habits[id]
+	s.habits[id] = cloneHabit(habit)
+	if !old.sameStreakRules(habit) {
+		index, err := buildStreakIndex(habit, s.completionsBetween(id, "", "9999-12-31"), s.clock.WeekStart)
+		if err != nil {
+			return err
+		}
+		s.streaks[id] = index
+	}
+	return s.logEvent(habitEvent(EventHabitUpdate, habit), habit)
+}
+
+// completionsBetween is CompletionsBetween for callers that hold s.mu.
+func (s *MemoryStore) completionsBetween(habitID, from, to string) []HabitCompletion {
+	var completions []HabitCompletion
+	for date, c := range s.completions[habitID] {
+		if date >= from && date <= to {
+			completions = append(completions, c)
+		}
+	}
+	sort.Slice(completions, func(i, j int) bool { return completions[i].Date < completions[j].Date })
+	return completions
+}
+
+// changeHabit mirrors the bbolt store's helper of the same name.
+func (s *MemoryStore) changeHabit(id, action string, change func(h *Habit)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.habits[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&h)
+	s.habits[id] = h
+	return s.logEvent(habitEvent(action, h), h)
+}
+
+func (s *MemoryStore) ArchiveHabit(id string) error {
+	return s.changeHabit(id, EventHabitArchive, func(h *Habit) { h.Archived = true })
+}
+
+func (s *MemoryStore) UnarchiveHabit(id string) error {
+	return s.changeHabit(id, EventHabitUnarchive, func(h *Habit) { h.Archived = false })
+}
+
+func (s *MemoryStore) DeleteHabit(id string) error {
+	return s.changeHabit(id, EventHabitDelete, func(h *Habit) { h.DeletedAt = now().Format(time.RFC3339) })
+}
+
+func (s *MemoryStore) RestoreHabit(id string) error {
+	return s.changeHabit(id, EventHabitRestore, func(h *Habit) { h.DeletedAt = "" })
+}
+
+func (s *MemoryStore) PurgeDeletedHabits(cutoff time.Time) ([]Habit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged []Habit
+	for _, h := range s.habits {
+		if deletedBefore(h, cutoff) {
+			purged = append(purged, cloneHabit(h))
+		}
+	}
+	sort.Slice(purged, func(i, j int) bool { return purged[i].ID < purged[j].ID })
+	for _, h := range purged {
+		s.deleteHabit(h.ID)
+		if err := s.logEvent(habitEvent(EventHabitPurge, h), nil); err != nil {
+			return nil, err
+		}
+	}
+	return purged, nil
+}
+
+func (s *MemoryStore) DeleteHabitPermanently(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.habits[id]
+	s.deleteHabit(id)
+	if !ok {
+		return nil
+	}
+	return s.logEvent(habitEvent(EventHabitPurge, h), nil)
+}
+
+// deleteHabit is for callers that hold s.mu.
+func (s *MemoryStore) deleteHabit(id string) {
+	delete(s.habits, id)
+	delete(s.completions, id)
+	delete(s.streaks, id)
+	delete(s.notes, id)
+}
+
+func (s *MemoryStore) ToggleHabitCompletion(habitID, date string) error {
+	return s.changeCompletion(EventCompletionToggle, habitID, date, func(old *HabitCompletion) *HabitCompletion {
+		if old != nil {
+			return nil
+		}
+		return &HabitCompletion{HabitID: habitID, Date: date, LoggedAt: logTime()}
+	})
+}
+
+func (s *MemoryStore) SetHabitValue(habitID, date string, value float64) error {
+	if err := ValidateValue(value); err != nil {
+		return err
+	}
+	return s.changeCompletion(EventCompletionValue, habitID, date, func(*HabitCompletion) *HabitCompletion {
+		if value <= 0 {
+			return nil
+		}
+		return &HabitCompletion{HabitID: habitID, Date: date, Value: value, LoggedAt: logTime()}
+	})
+}
+
+// changeCompletion mirrors the bbolt store's helper of the same name. Like
+// a bbolt transaction it changes nothing unless every step succeeds: the
+// streak index is updated on a copy, against the completions as they will
+// be, and the change is stored only once the event is logged.
+func (s *MemoryStore) changeCompletion(action, habitID, date string, change func(old *HabitCompletion) *HabitCompletion) error {
+	day, err := dayNumber(date)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := &streakIndex{}
+	if x := s.streaks[habitID]; x != nil {
+		index = &streakIndex{Runs: slices.Clone(x.Runs), Total: x.Total}
+	}
+
+	var old *HabitCompletion
+	if c, ok := s.completions[habitID][date]; ok {
+		old = &c
+		index.Total--
+	}
+	c := change(old)
+	if c != nil {
+		index.Total++
+	}
+	err = index.update(s.habits[habitID], day, s.clock.WeekStart, func(from, to string) ([]HabitCompletion, error) {
+		completions := slices.DeleteFunc(s.completionsBetween(habitID, from, to), func(o HabitCompletion) bool { return o.Date == date })
+		if c != nil {
+			completions = append(completions, *c)
+			sort.Slice(completions, func(i, j int) bool { return completions[i].Date < completions[j].Date })
+		}
+		return completions, nil
+	})
+	if err != nil {
+		return err
+	}
+	if old != nil || c != nil {
+		event := Event{Action: action, HabitID: habitID, Name: s.habits[habitID].Name, Date: date}
+		if err := s.logEvent(event, c); err != nil {
+			return err
+		}
+	}
+
+	byDate, ok := s.completions[habitID]
+	if !ok {
+		byDate = make(map[string]HabitCompletion)
+		s.completions[habitID] = byDate
+	}
+	if c != nil {
+		byDate[date] = *c
+	} else {
+		delete(byDate, date)
+	}
+	s.streaks[habitID] = index
+	return nil
+}
+
+func (s *MemoryStore) IsHabitCompleted(habitID, date string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.completions[habitID][date]
+	return ok, nil
+}
+
+func (s *MemoryStore) CompletionsBetween(habitID, from, to string) ([]HabitCompletion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.completionsBetween(habitID, from, to), nil
+}
+
+func (s *MemoryStore) SetNote(habitID, date, text string) error {
+	if _, err := dayNumber(date); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notes[habitID][date].Text == text {
+		return nil
+	}
+	event := Event{Action: EventNoteSet, HabitID: habitID, Name: s.habits[habitID].Name, Date: date}
+	if text == "" {
+		delete(s.notes[habitID], date)
+		return s.logEvent(event, nil)
+	}
+	if s.notes[habitID] == nil {
+		s.notes[habitID] = make(map[string]DayNote)
+	}
+	note := DayNote{HabitID: habitID, Date: date, Text: text}
+	s.notes[habitID][date] = note
+	return s.logEvent(event, note)
+}
+
+func (s *MemoryStore) NotesBetween(habitID, from, to string) ([]DayNote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var notes []DayNote
+	for date, n := range s.notes[habitID] {
+		if date >= from && date <= to {
+			notes = append(notes, n)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Date < notes[j].Date })
+	return notes, nil
+}
+
+func (s *MemoryStore) GetHabitStats(habitID string) (StreakStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index, ok := s.streaks[habitID]
+	if !ok {
+		index = &streakIndex{}
+	}
+	return index.stats(s.habits[habitID], s.clock.todayNumber(), s.clock.WeekStart), nil
+}
+
+func (s *MemoryStore) GetHabitStreak(habitID string) (int, error) {
+	stats, err := s.GetHabitStats(habitID)
+	return stats.Current, err
+}
+
+func (s *MemoryStore) GetHabitLongestStreak(habitID string) (int, error) {
+	stats, err := s.GetHabitStats(habitID)
+	return stats.Longest, err
+}
+
+func (s *MemoryStore) AddTask(id, name, description, dueDate string) error {
+	task := Task{
+		ID:          id,
+		Name:        name,
+		Description: description,
+		DueDate:     dueDate,
+		Completed:   false,
+		CreatedAt:   now().Format("2006-01-02 15:04:05"),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[id] = task
+	return s.logEvent(taskEvent(EventTaskCreate, task), task)
+}
+
+func (s *MemoryStore) GetTasks() ([]Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var tasks []Task
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+func (s *MemoryStore) UpdateTask(id string, task Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.tasks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	old.Name = task.Name
+	old.Description = task.Description
+	old.DueDate = task.DueDate
+	s.tasks[id] = old
+	return s.logEvent(taskEvent(EventTaskUpdate, old), old)
+}
+
+func (s *MemoryStore) ToggleTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil
+	}
+	task.Completed = !task.Completed
+	s.tasks[id] = task
+	return s.logEvent(taskEvent(EventTaskToggle, task), task)
+}
+
+func (s *MemoryStore) DeleteTask(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[id]
+	if !ok {
+		return nil
+	}
+	delete(s.tasks, id)
+	return s.logEvent(taskEvent(EventTaskDelete, task), nil)
+}
+
+func (s *MemoryStore) Clock() Clock {
+	return s.clock
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *MemoryStore) GetHistory() (undo, redo []Operation, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.undo), slices.Clone(s.redo), nil
+}
+
+func (s *MemoryStore) SaveHistory(undo, redo []Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.undo, s.redo = slices.Clone(undo), slices.Clone(redo)
+	return nil
+}
+
+func (s *MemoryStore) Events(habitID string, since time.Time, limit int) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var events []Event
+	for i := len(s.events) - 1; i >= 0 && (limit <= 0 || len(events) < limit); i-- {
+		e := s.events[i]
+		if !since.IsZero() && e.before(since) {
+			break
+		}
+		if habitID == "" || e.HabitID == habitID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// logEvent is appendEvent for callers that hold s.mu.
+func (s *MemoryStore) logEvent(e Event, record any) error {
+	e, err := e.stamp(record)
+	if err != nil {
+		return err
+	}
+	e.Seq = uint64(len(s.events) + 1)
+	s.events = append(s.events, e)
+	return nil
+}
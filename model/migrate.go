@@ -0,0 +1,406 @@
+// File: model/migrate.go
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket       = []byte("meta")
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migration upgrades the database from version-1 to version. All pending
+// migrations run inside a single read-write transaction, so a failure
+// leaves the database exactly as it was. clock is the clock the store is
+// being opened with.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *bolt.Tx, clock Clock) error
+}
+
+// migrations must stay ordered by version. Never edit a step that has
+// shipped; append a new one instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create habits, completions and tasks buckets",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			for _, name := range [][]byte{habitsBucket, completionsBucket, tasksBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     2,
+		description: "move completions into per-habit buckets keyed by date",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			b := tx.Bucket(completionsBucket)
+			var flat []HabitCompletion
+			var keys [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return nil // already a nested bucket
+				}
+				var c HabitCompletion
+				if err := json.Unmarshal(v, &c); err != nil {
+					return fmt.Errorf("completion %q: %w", k, err)
+				}
+				flat = append(flat, c)
+				keys = append(keys, append([]byte(nil), k...))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			for _, c := range flat {
+				hb, err := b.CreateBucketIfNotExists([]byte(c.HabitID))
+				if err != nil {
+					return err
+				}
+				data, err := json.Marshal(c)
+				if err != nil {
+					return err
+				}
+				if err := hb.Put([]byte(c.Date), data); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     3,
+		description: "index completion runs for streaks",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			if _, err := tx.CreateBucketIfNotExists(streaksBucket); err != nil {
+				return err
+			}
+			// Sunday weeks, as before the week start was configurable;
+			// OpenBoltStore's syncWeekStart moves them to clock's.
+			return tx.Bucket(completionsBucket).ForEachBucket(func(habitID []byte) error {
+				return rebuildStreakIndex(tx, Habit{ID: string(habitID)}, time.Sunday)
+			})
+		},
+	},
+	{
+		version:     4,
+		description: "move weekday notes to dated notes",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			if _, err := tx.CreateBucketIfNotExists(notesBucket); err != nil {
+				return err
+			}
+			habits := tx.Bucket(habitsBucket)
+			var updated []Habit
+			err := habits.ForEach(func(k, v []byte) error {
+				var h Habit
+				if err := json.Unmarshal(v, &h); err != nil {
+					return fmt.Errorf("habit %q: %w", k, err)
+				}
+				moved := false
+				for key, text := range h.Notes {
+					weekday, ok := parseWeekdayName(key)
+					if !ok {
+						continue // e.g. the habit-wide "general" note
+					}
+					date, err := weekdayNoteDate(tx, h.ID, weekday, clock.todayNumber())
+					if err != nil {
+						return err
+					}
+					if err := putNote(tx, DayNote{HabitID: h.ID, Date: date, Text: text}); err != nil {
+						return err
+					}
+					delete(h.Notes, key)
+					moved = true
+				}
+				if moved {
+					updated = append(updated, h)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, h := range updated {
+				data, err := json.Marshal(h)
+				if err != nil {
+					return err
+				}
+				if err := habits.Put([]byte(h.ID), data); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     5,
+		description: "create the undo history bucket",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			_, err := tx.CreateBucketIfNotExists(historyBucket)
+			return err
+		},
+	},
+	{
+		version:     6,
+		description: "create the events bucket",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			_, err := tx.CreateBucketIfNotExists(eventsBucket)
+			return err
+		},
+	},
+	{
+		version:     7,
+		description: "move habit-wide notes to dated notes",
+		up: func(tx *bolt.Tx, clock Clock) error {
+			habits := tx.Bucket(habitsBucket)
+			var updated []Habit
+			err := habits.ForEach(func(k, v []byte) error {
+				var h Habit
+				if err := json.Unmarshal(v, &h); err != nil {
+					return fmt.Errorf("habit %q: %w", k, err)
+				}
+				if len(h.Notes) == 0 {
+					return nil
+				}
+				date, err := habitNoteDate(tx, h.ID, clock.todayNumber())
+				if err != nil {
+					return err
+				}
+				existing, err := loadNote(tx, h.ID, date)
+				if err != nil {
+					return err
+				}
+				var texts []string
+				if existing != "" {
+					texts = append(texts, existing)
+				}
+				for _, key := range slices.Sorted(maps.Keys(h.Notes)) {
+					if text := strings.TrimSpace(h.Notes[key]); text != "" {
+						texts = append(texts, text)
+					}
+				}
+				if err := putNote(tx, DayNote{HabitID: h.ID, Date: date, Text: strings.Join(texts, "\n")}); err != nil {
+					return err
+				}
+				h.Notes = nil
+				updated = append(updated, h)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, h := range updated {
+				data, err := json.Marshal(h)
+				if err != nil {
+					return err
+				}
+				if err := habits.Put([]byte(h.ID), data); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func parseWeekdayName(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if d.String() == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// weekdayNoteDate picks the date a legacy weekday note most likely belongs
+// to. The note was overwritten every week, so it is the latest such day:
+// the most recent completion on that weekday, or failing that the most
+// recent occurrence of the weekday.
+func weekdayNoteDate(tx *bolt.Tx, habitID string, weekday time.Weekday, today int) (string, error) {
+	if b := tx.Bucket(completionsBucket).Bucket([]byte(habitID)); b != nil {
+		c := b.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			day, err := dayNumber(string(k))
+			if err != nil {
+				return "", err
+			}
+			if day <= today && weekdayOf(day) == weekday {
+				return string(k), nil
+			}
+		}
+	}
+	return dayDate(today - floorMod(int(weekdayOf(today)-weekday), 7)), nil
+}
+
+// habitNoteDate picks the date a habit-wide note such as the old
+// "general" one moves to: the habit's most recent completion, or today if
+// it has none. The note was kept up to date, so that is where it was last
+// relevant.
+func habitNoteDate(tx *bolt.Tx, habitID string, today int) (string, error) {
+	if b := tx.Bucket(completionsBucket).Bucket([]byte(habitID)); b != nil {
+		c := b.Cursor()
+		for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+			day, err := dayNumber(string(k))
+			if err != nil {
+				return "", err
+			}
+			if day <= today {
+				return string(k), nil
+			}
+		}
+	}
+	return dayDate(today), nil
+}
+
+// SchemaVersion is the schema version this build reads and writes.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+type MigrationStep struct {
+	Version     int
+	Description string
+}
+
+// MigrationPlan describes the steps needed to bring a database from From
+// to To. Steps is empty when the database is already current.
+type MigrationPlan struct {
+	From  int
+	To    int
+	Steps []MigrationStep
+}
+
+// MigrationResult is the outcome of MigrateBoltStore.
+type MigrationResult struct {
+	MigrationPlan
+	// BackupPath is the copy taken before migrating, or "" if none was needed.
+	BackupPath string
+}
+
+// readSchemaVersion returns 0 for databases created before versioning.
+func readSchemaVersion(tx *bolt.Tx) (int, error) {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0, nil
+	}
+	v := b.Get(schemaVersionKey)
+	if v == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", v, err)
+	}
+	return version, nil
+}
+
+func planMigrations(tx *bolt.Tx) (MigrationPlan, error) {
+	from, err := readSchemaVersion(tx)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	plan := MigrationPlan{From: from, To: SchemaVersion()}
+	if from > plan.To {
+		return plan, fmt.Errorf("database schema v%d is newer than this build supports (v%d)", from, plan.To)
+	}
+	for _, m := range migrations {
+		if m.version > from {
+			plan.Steps = append(plan.Steps, MigrationStep{Version: m.version, Description: m.description})
+		}
+	}
+	return plan, nil
+}
+
+// isEmpty reports whether the database has no buckets at all, i.e. it was
+// just created and there is nothing worth backing up.
+func isEmpty(tx *bolt.Tx) bool {
+	empty := true
+	tx.ForEach(func([]byte, *bolt.Bucket) error {
+		empty = false
+		return nil
+	})
+	return empty
+}
+
+// migrate backs up the file at path and applies every pending migration.
+func migrate(db *bolt.DB, path string, clock Clock) (MigrationResult, error) {
+	var result MigrationResult
+	var empty bool
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		result.MigrationPlan, err = planMigrations(tx)
+		if err != nil || len(result.Steps) == 0 {
+			return err
+		}
+		if empty = isEmpty(tx); empty {
+			return nil
+		}
+		result.BackupPath = fmt.Sprintf("%s.v%d.%s.bak", path, result.From, time.Now().Format("20060102-150405"))
+		return tx.CopyFile(result.BackupPath, 0600)
+	})
+	if err != nil || len(result.Steps) == 0 {
+		return result, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, m := range migrations {
+			if m.version <= result.From {
+				continue
+			}
+			if err := m.up(tx, clock); err != nil {
+				return fmt.Errorf("migration v%d (%s): %w", m.version, m.description, err)
+			}
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, []byte(strconv.Itoa(result.To)))
+	})
+	return result, err
+}
+
+// PlanBoltMigrations opens the database at path read-only and reports the
+// migrations OpenBoltStore would apply, without changing anything.
+func PlanBoltMigrations(path string) (MigrationPlan, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+	defer db.Close()
+
+	var plan MigrationPlan
+	err = db.View(func(tx *bolt.Tx) error {
+		plan, err = planMigrations(tx)
+		return err
+	})
+	return plan, err
+}
+
+// MigrateBoltStore brings the database at path up to date and closes it.
+func MigrateBoltStore(path string, clock Clock) (MigrationResult, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	defer db.Close()
+	return migrate(db, path, clock)
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// createLegacyDB writes a database the way builds before schema versioning
+// did: the three data buckets and no meta bucket.
+func createLegacyDB(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(habitsBucket)
+		if err != nil {
+			return err
+		}
+		c, err := tx.CreateBucket(completionsBucket)
+		if err != nil {
+			return err
+		}
+		if err := c.Put([]byte("1_2024-03-01"), []byte(`{"habit_id":"1","date":"2024-03-01"}`)); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(tasksBucket); err != nil {
+			return err
+		}
+		return b.Put([]byte("1"), []byte(`{"id":"1","name":"read","type":"general","archived":false}`))
+	})
+	if err != nil {
+		t.Fatalf("seed legacy db: %v", err)
+	}
+	db.Close()
+	return path
+}
+
+func TestPlanBoltMigrationsIsReadOnly(t *testing.T) {
+	path := createLegacyDB(t)
+
+	plan, err := PlanBoltMigrations(path)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if plan.From != 0 || plan.To != SchemaVersion() || len(plan.Steps) != SchemaVersion() {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	again, err := PlanBoltMigrations(path)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if again.From != 0 {
+		t.Fatalf("dry run changed schema version to %d", again.From)
+	}
+}
+
+func TestMigrateLegacyDBKeepsDataAndBacksUp(t *testing.T) {
+	path := createLegacyDB(t)
+
+	result, err := MigrateBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.BackupPath == "" {
+		t.Fatalf("expected a backup of the legacy db")
+	}
+	if _, err := os.Stat(result.BackupPath); err != nil {
+		t.Fatalf("backup missing: %v", err)
+	}
+
+	s, err := OpenBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	habits, _ := s.GetHabits()
+	if len(habits) != 1 || habits[0].Name != "read" {
+		t.Fatalf("legacy habit lost: %+v", habits)
+	}
+	if done, _ := s.IsHabitCompleted("1", "2024-03-01"); !done {
+		t.Fatalf("legacy completion lost")
+	}
+	if stats, _ := s.GetHabitStats("1"); stats.Total != 1 || stats.LongestStart != "2024-03-01" {
+		t.Fatalf("legacy completion not indexed for streaks: %+v", stats)
+	}
+
+	again, err := migrate(s.db, path, Clock{})
+	if err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	if len(again.Steps) != 0 || again.BackupPath != "" {
+		t.Fatalf("expected no-op on current db, got %+v", again)
+	}
+}
+
+func TestNewDBSkipsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.db")
+	result, err := MigrateBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if result.BackupPath != "" {
+		t.Fatalf("did not expect a backup for a new db, got %s", result.BackupPath)
+	}
+	if result.To != SchemaVersion() {
+		t.Fatalf("expected schema v%d, got v%d", SchemaVersion(), result.To)
+	}
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	path := createLegacyDB(t)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.Update(func(tx *bolt.Tx) error {
+		meta, _ := tx.CreateBucketIfNotExists(metaBucket)
+		return meta.Put(schemaVersionKey, []byte(strconv.Itoa(SchemaVersion()+1)))
+	})
+	db.Close()
+
+	if _, err := OpenBoltStore(path, Clock{}); err == nil {
+		t.Fatalf("expected error opening a db from a newer build")
+	}
+}
+
+func TestMigrateWeekdayNotesToDates(t *testing.T) {
+	path := createLegacyDB(t)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// 2024-03-01 is a Friday and the habit's only completion.
+	db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(habitsBucket).Put([]byte("1"),
+			[]byte(`{"id":"1","name":"read","type":"daily","notes":{"Friday":"finished the book","Monday":"started","general":"fiction only"}}`))
+	})
+	db.Close()
+
+	s, err := OpenBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	notes, _ := s.NotesBetween("1", "2024-03-01", "2024-03-01")
+	if len(notes) != 1 || notes[0].Text != "finished the book\nfiction only" {
+		t.Fatalf("expected the Friday note and then the general one on the Friday completion, got %+v", notes)
+	}
+	monday, _ := s.NotesBetween("1", "2024-03-02", "9999-12-31")
+	if len(monday) != 1 || monday[0].Text != "started" {
+		t.Fatalf("expected the Monday note on a recent Monday, got %+v", monday)
+	}
+	if d, _ := time.Parse("2006-01-02", monday[0].Date); d.Weekday() != time.Monday || monday[0].Date > s.Clock().Today().Format("2006-01-02") {
+		t.Fatalf("expected a past Monday, got %s", monday[0].Date)
+	}
+
+	habits, _ := s.GetHabits()
+	if len(habits[0].Notes) != 0 {
+		t.Fatalf("expected no notes left on the habit, got %+v", habits[0].Notes)
+	}
+}
+
+func TestMigrateGeneralNoteToLatestCompletion(t *testing.T) {
+	path := createLegacyDB(t)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	// What the TUI used to save for a general habit after n: one note for
+	// the whole habit, keyed "general". The habit's only completion is
+	// 2024-03-01; the second habit has none.
+	db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(habitsBucket)
+		if err := b.Put([]byte("1"), []byte(`{"id":"1","name":"read","type":"general","notes":{"general":"fiction only"},"archived":false}`)); err != nil {
+			return err
+		}
+		return b.Put([]byte("2"), []byte(`{"id":"2","name":"run","type":"general","notes":{"general":"5k loop"},"archived":false}`))
+	})
+	db.Close()
+
+	s, err := OpenBoltStore(path, Clock{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	notes, _ := s.NotesBetween("1", "0000-01-01", "9999-12-31")
+	if len(notes) != 1 || notes[0].Date != "2024-03-01" || notes[0].Text != "fiction only" {
+		t.Fatalf("expected the general note on the latest completion, got %+v", notes)
+	}
+	today := s.Clock().Today().Format("2006-01-02")
+	if notes, _ := s.NotesBetween("2", "0000-01-01", "9999-12-31"); len(notes) != 1 || notes[0].Date != today || notes[0].Text != "5k loop" {
+		t.Fatalf("expected the general note of a habit never done on %s, got %+v", today, notes)
+	}
+	habits, _ := s.GetHabits()
+	for _, h := range habits {
+		if len(h.Notes) != 0 {
+			t.Fatalf("expected no notes left on %q, got %+v", h.Name, h.Notes)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// File: model/schedule.go
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ScheduleDaily    = "daily"
+	ScheduleWeekdays = "weekdays" // on specific days of the week
+	ScheduleWeekly   = "weekly"   // Times per week, any days
+	ScheduleMonthly  = "monthly"  // Times per month, any days
+	ScheduleInterval = "interval" // once in every Every days, counted from Anchor
+)
+
+// Schedule says when a habit is due. The zero value is daily.
+//
+// Streaks are counted in periods rather than days: a period is one due day
+// for daily and weekday schedules, a week or month for quota schedules and
+// an Every-day window for intervals. A period is met once it has Times
+// completions (or one, for the schedules that don't use Times).
+type Schedule struct {
+	Kind     string         `json:"kind,omitempty"`
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	Times    int            `json:"times,omitempty"`
+	Every    int            `json:"every,omitempty"`
+	Anchor   string         `json:"anchor,omitempty"`
+}
+
+func (s Schedule) kind() string {
+	if s.Kind == "" {
+		return ScheduleDaily
+	}
+	return s.Kind
+}
+
+func (s Schedule) Equal(o Schedule) bool {
+	return s.kind() == o.kind() && slices.Equal(s.weekdays(), o.weekdays()) &&
+		s.Times == o.Times && s.Every == o.Every && s.Anchor == o.Anchor
+}
+
+func (s Schedule) Validate() error {
+	switch s.kind() {
+	case ScheduleDaily:
+	case ScheduleWeekdays:
+		if len(s.Weekdays) == 0 {
+			return fmt.Errorf("weekday schedule needs at least one day")
+		}
+		for _, d := range s.Weekdays {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("invalid weekday %d", d)
+			}
+		}
+	case ScheduleWeekly:
+		if s.Times < 1 || s.Times > 7 {
+			return fmt.Errorf("times per week must be between 1 and 7")
+		}
+	case ScheduleMonthly:
+		if s.Times < 1 || s.Times > 31 {
+			return fmt.Errorf("times per month must be between 1 and 31")
+		}
+	case ScheduleInterval:
+		if s.Every < 1 {
+			return fmt.Errorf("interval must be at least 1 day")
+		}
+		if _, err := dayNumber(s.Anchor); err != nil {
+			return fmt.Errorf("interval anchor: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown schedule kind %q", s.Kind)
+	}
+	return nil
+}
+
+// Unit names one streak period, e.g. "week" for "3 weeks in a row".
+func (s Schedule) Unit() string {
+	switch s.kind() {
+	case ScheduleWeekly:
+		return "week"
+	case ScheduleMonthly:
+		return "month"
+	case ScheduleInterval:
+		return "cycle"
+	default:
+		return "day"
+	}
+}
+
+// IsDue reports whether date is a day the habit is scheduled for. Quota
+// schedules can be done on any day, so every day is due.
+func (s Schedule) IsDue(date string) bool {
+	day, err := dayNumber(date)
+	if err != nil {
+		return false
+	}
+	switch s.kind() {
+	case ScheduleWeekdays:
+		return slices.Contains(s.Weekdays, weekdayOf(day))
+	case ScheduleInterval:
+		anchor, _ := dayNumber(s.Anchor)
+		return floorMod(day-anchor, max(s.Every, 1)) == 0
+	default:
+		return true
+	}
+}
+
+func (s Schedule) required() int {
+	switch s.kind() {
+	case ScheduleWeekly, ScheduleMonthly:
+		return max(s.Times, 1)
+	default:
+		return 1
+	}
+}
+
+func (s Schedule) weekdays() []time.Weekday {
+	days := slices.Clone(s.Weekdays)
+	slices.Sort(days)
+	return slices.Compact(days)
+}
+
+// weekOrder is weekdays in the order they fall in a week starting on
+// weekStart. Periods are numbered in this order.
+func (s Schedule) weekOrder(weekStart time.Weekday) []time.Weekday {
+	days := s.weekdays()
+	slices.SortFunc(days, func(a, b time.Weekday) int { return weekOffset(a, weekStart) - weekOffset(b, weekStart) })
+	return days
+}
+
+// period returns the period containing day, with weeks starting on
+// weekStart. ok is false for days that belong to no period, i.e. the off
+// days of a weekday schedule.
+func (s Schedule) period(day int, weekStart time.Weekday) (p int, ok bool) {
+	switch s.kind() {
+	case ScheduleWeekdays:
+		days := s.weekOrder(weekStart)
+		i := slices.Index(days, weekdayOf(day))
+		if i < 0 {
+			return 0, false
+		}
+		return weekOf(day, weekStart)*len(days) + i, true
+	case ScheduleWeekly:
+		return weekOf(day, weekStart), true
+	case ScheduleMonthly:
+		t := time.Unix(int64(day)*86400, 0).UTC()
+		return t.Year()*12 + int(t.Month()) - 1, true
+	case ScheduleInterval:
+		anchor, _ := dayNumber(s.Anchor)
+		return floorDiv(day-anchor, max(s.Every, 1)), true
+	default:
+		return day, true
+	}
+}
+
+// periodAtOrBefore returns the latest period that starts on or before day.
+func (s Schedule) periodAtOrBefore(day int, weekStart time.Weekday) int {
+	if p, ok := s.period(day, weekStart); ok {
+		return p
+	}
+	days := s.weekOrder(weekStart)
+	off := weekOffset(weekdayOf(day), weekStart)
+	w := weekOf(day, weekStart)
+	for i := len(days) - 1; i >= 0; i-- {
+		if weekOffset(days[i], weekStart) < off {
+			return w*len(days) + i
+		}
+	}
+	return w*len(days) - 1
+}
+
+// periodRange returns the first and last day of period p.
+func (s Schedule) periodRange(p int, weekStart time.Weekday) (from, to int) {
+	switch s.kind() {
+	case ScheduleWeekdays:
+		days := s.weekOrder(weekStart)
+		day := weekFirstDay(floorDiv(p, len(days)), weekStart) + weekOffset(days[floorMod(p, len(days))], weekStart)
+		return day, day
+	case ScheduleWeekly:
+		return weekFirstDay(p, weekStart), weekFirstDay(p, weekStart) + 6
+	case ScheduleMonthly:
+		first := time.Date(floorDiv(p, 12), time.Month(floorMod(p, 12)+1), 1, 0, 0, 0, 0, time.UTC)
+		from = int(first.Unix() / 86400)
+		return from, int(first.AddDate(0, 1, -1).Unix() / 86400)
+	case ScheduleInterval:
+		anchor, _ := dayNumber(s.Anchor)
+		every := max(s.Every, 1)
+		return anchor + p*every, anchor + p*every + every - 1
+	default:
+		return p, p
+	}
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func (s Schedule) String() string {
+	switch s.kind() {
+	case ScheduleWeekdays:
+		var names []string
+		for _, d := range s.weekdays() {
+			names = append(names, weekdayNames[d])
+		}
+		return strings.Join(names, ",")
+	case ScheduleWeekly:
+		return fmt.Sprintf("%d/week", s.Times)
+	case ScheduleMonthly:
+		return fmt.Sprintf("%d/month", s.Times)
+	case ScheduleInterval:
+		return fmt.Sprintf("every %d days", s.Every)
+	default:
+		return ScheduleDaily
+	}
+}
+
+// ParseSchedule reads the forms String produces: "daily", "mon,wed,fri",
+// "3/week", "2/month" and "every 3 days". Interval schedules are anchored
+// at today.
+func ParseSchedule(text string, today time.Time) (Schedule, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	var s Schedule
+	switch {
+	case text == "" || text == ScheduleDaily:
+		s.Kind = ScheduleDaily
+	case strings.HasSuffix(text, "/week"):
+		n, err := strconv.Atoi(strings.TrimSuffix(text, "/week"))
+		if err != nil {
+			return s, fmt.Errorf("invalid schedule %q", text)
+		}
+		s = Schedule{Kind: ScheduleWeekly, Times: n}
+	case strings.HasSuffix(text, "/month"):
+		n, err := strconv.Atoi(strings.TrimSuffix(text, "/month"))
+		if err != nil {
+			return s, fmt.Errorf("invalid schedule %q", text)
+		}
+		s = Schedule{Kind: ScheduleMonthly, Times: n}
+	case strings.HasPrefix(text, "every "):
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(text, "every "), " days"), " day"))
+		if err != nil {
+			return s, fmt.Errorf("invalid schedule %q", text)
+		}
+		s = Schedule{Kind: ScheduleInterval, Every: n, Anchor: today.Format("2006-01-02")}
+	default:
+		s.Kind = ScheduleWeekdays
+		for _, name := range strings.Split(text, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 3 {
+				name = name[:3] // "monday" -> "mon"
+			}
+			i := slices.Index(weekdayNames, name)
+			if i < 0 {
+				return Schedule{}, fmt.Errorf("invalid schedule %q", text)
+			}
+			s.Weekdays = append(s.Weekdays, time.Weekday(i))
+		}
+		s.Weekdays = s.weekdays()
+	}
+	return s, s.Validate()
+}
+
+// Day numbers count from 1970-01-01, a Thursday. Weeks start on the clock's
+// WeekStart, Sunday unless configured otherwise.
+
+func weekdayOf(day int) time.Weekday {
+	return time.Weekday(floorMod(day+4, 7))
+}
+
+// weekOffset is d's position in a week starting on weekStart, 0 for the
+// first day.
+func weekOffset(d, weekStart time.Weekday) int {
+	return floorMod(int(d)-int(weekStart), 7)
+}
+
+func weekOf(day int, weekStart time.Weekday) int {
+	return floorDiv(day+4-int(weekStart), 7)
+}
+
+func weekFirstDay(week int, weekStart time.Weekday) int {
+	return week*7 - 4 + int(weekStart)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleRoundTrip(t *testing.T) {
+	for _, text := range []string{"daily", "mon,wed,fri", "3/week", "2/month", "every 3 days"} {
+		s, err := ParseSchedule(text, time.Now())
+		if err != nil {
+			t.Fatalf("parse %q: %v", text, err)
+		}
+		if s.String() != text {
+			t.Fatalf("expected %q, got %q", text, s.String())
+		}
+	}
+
+	s, err := ParseSchedule("Friday, monday", time.Now())
+	if err != nil || s.String() != "mon,fri" {
+		t.Fatalf("expected mon,fri, got %q (%v)", s.String(), err)
+	}
+
+	for _, text := range []string{"8/week", "0/month", "every 0 days", "someday"} {
+		if _, err := ParseSchedule(text, time.Now()); err == nil {
+			t.Fatalf("expected %q to be rejected", text)
+		}
+	}
+}
+
+func TestScheduleIsDue(t *testing.T) {
+	mwf := Schedule{Kind: ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	// 2024-01-01 was a Monday
+	for date, want := range map[string]bool{"2024-01-01": true, "2024-01-02": false, "2024-01-05": true, "2024-01-07": false} {
+		if got := mwf.IsDue(date); got != want {
+			t.Fatalf("%s: expected due=%v, got %v", date, want, got)
+		}
+	}
+
+	every3 := Schedule{Kind: ScheduleInterval, Every: 3, Anchor: "2024-01-01"}
+	for date, want := range map[string]bool{"2023-12-29": true, "2024-01-01": true, "2024-01-02": false, "2024-01-04": true} {
+		if got := every3.IsDue(date); got != want {
+			t.Fatalf("%s: expected due=%v, got %v", date, want, got)
+		}
+	}
+}
+
+func TestSchedulePeriodRanges(t *testing.T) {
+	schedules := []Schedule{
+		{},
+		{Kind: ScheduleWeekdays, Weekdays: []time.Weekday{time.Tuesday, time.Saturday}},
+		{Kind: ScheduleWeekly, Times: 2},
+		{Kind: ScheduleMonthly, Times: 4},
+		{Kind: ScheduleInterval, Every: 5, Anchor: "2024-02-03"},
+	}
+	start, _ := dayNumber("2023-12-01")
+	for _, s := range schedules {
+		for day := start; day < start+120; day++ {
+			p, ok := s.period(day, time.Sunday)
+			if !ok {
+				if s.IsDue(dayDate(day)) {
+					t.Fatalf("%s: %s is due but has no period", s, dayDate(day))
+				}
+				continue
+			}
+			from, to := s.periodRange(p, time.Sunday)
+			if day < from || day > to {
+				t.Fatalf("%s: %s not inside its period %s..%s", s, dayDate(day), dayDate(from), dayDate(to))
+			}
+			if got := s.periodAtOrBefore(day, time.Sunday); got != p {
+				t.Fatalf("%s: periodAtOrBefore(%s) = %d, want %d", s, dayDate(day), got, p)
+			}
+		}
+	}
+}
+
+func TestWeekdayScheduleSkipsOffDays(t *testing.T) {
+	mwf := Schedule{Kind: ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	x, _ := buildStreakIndex(Habit{Schedule: mwf}, []HabitCompletion{
+		{Date: "2024-01-01"}, {Date: "2024-01-03"}, {Date: "2024-01-05"}, {Date: "2024-01-08"},
+	}, time.Sunday)
+
+	// Tuesday the 9th is an off day; Monday the 8th is still the latest due day
+	today, _ := dayNumber("2024-01-09")
+	got := x.stats(Habit{Schedule: mwf}, today, time.Sunday)
+	if got.Current != 4 || got.Longest != 4 || got.LongestStart != "2024-01-01" || got.LongestEnd != "2024-01-08" {
+		t.Fatalf("unexpected stats: %+v", got)
+	}
+
+	// missing Wednesday the 10th breaks it once Thursday comes
+	today, _ = dayNumber("2024-01-11")
+	if got := x.stats(Habit{Schedule: mwf}, today, time.Sunday); got.Current != 0 {
+		t.Fatalf("expected streak broken, got %+v", got)
+	}
+}
+
+func TestWeeklyQuotaStreak(t *testing.T) {
+	gym := Schedule{Kind: ScheduleWeekly, Times: 3}
+	x, _ := buildStreakIndex(Habit{Schedule: gym}, []HabitCompletion{
+		// week of Sun 2023-12-31: three sessions
+		{Date: "2024-01-01"}, {Date: "2024-01-03"}, {Date: "2024-01-05"},
+		// week of Sun 2024-01-07: three sessions
+		{Date: "2024-01-07"}, {Date: "2024-01-09"}, {Date: "2024-01-12"},
+		// week of Sun 2024-01-14: one so far
+		{Date: "2024-01-15"},
+	}, time.Sunday)
+
+	today, _ := dayNumber("2024-01-16")
+	got := x.stats(Habit{Schedule: gym}, today, time.Sunday)
+	if got.Current != 2 || got.Unit != "week" || got.Total != 7 {
+		t.Fatalf("unexpected stats mid-week: %+v", got)
+	}
+	if got.LongestStart != "2023-12-31" || got.LongestEnd != "2024-01-13" {
+		t.Fatalf("unexpected longest range: %+v", got)
+	}
+}
+
+func TestWeekdayPeriodsFollowWeekStart(t *testing.T) {
+	s := Schedule{Kind: ScheduleWeekdays, Weekdays: []time.Weekday{time.Sunday, time.Monday}}
+	// Mon 2024-01-01, Sun 2024-01-07 and Mon 2024-01-08 are consecutive due days.
+	var periods []int
+	for _, date := range []string{"2024-01-01", "2024-01-07", "2024-01-08"} {
+		day, _ := dayNumber(date)
+		p, ok := s.period(day, time.Monday)
+		if !ok {
+			t.Fatalf("%s should be due", date)
+		}
+		if from, _ := s.periodRange(p, time.Monday); from != day {
+			t.Fatalf("%s: period %d starts on %s", date, p, dayDate(from))
+		}
+		periods = append(periods, p)
+	}
+	if periods[1] != periods[0]+1 || periods[2] != periods[1]+1 {
+		t.Fatalf("expected consecutive periods, got %v", periods)
+	}
+}
@@ -0,0 +1,122 @@
+// File: model/store.go
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a habit or task ID does not exist.
+var ErrNotFound = errors.New("not found")
+
+type Habit struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Type        string            `json:"type"`  // "general" or "daily"
+	Notes       map[string]string `json:"notes"` // legacy notes, moved to DayNote by migrations
+	Archived    bool              `json:"archived"`
+	Schedule    Schedule          `json:"schedule"`
+	Goal        *Goal             `json:"goal,omitempty"`
+	Kind        string            `json:"kind,omitempty"` // KindBuild or KindQuit
+	CreatedAt   string            `json:"created_at,omitempty"`
+	DeletedAt   string            `json:"deleted_at,omitempty"` // RFC 3339; set while the habit waits to be purged
+}
+
+// DeleteGracePeriod is how long a deleted habit can be restored before
+// purging removes it for good.
+const DeleteGracePeriod = 30 * 24 * time.Hour
+
+const (
+	KindBuild = ""     // something to do; each entry is a completion
+	KindQuit  = "quit" // something to avoid; each entry is a relapse
+)
+
+func (h Habit) IsQuit() bool {
+	return h.Kind == KindQuit
+}
+
+type HabitCompletion struct {
+	HabitID  string  `json:"habit_id"`
+	Date     string  `json:"date"`
+	Value    float64 `json:"value,omitempty"`     // only used by habits with a Goal
+	LoggedAt string  `json:"logged_at,omitempty"` // RFC 3339; empty for entries made before it was recorded
+}
+
+// DayNote is free text attached to a habit on one date, whether or not the
+// habit was done that day.
+type DayNote struct {
+	HabitID string `json:"habit_id"`
+	Date    string `json:"date"`
+	Text    string `json:"text"`
+}
+
+type Task struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	Completed   bool   `json:"completed"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Store is the persistence layer for habits, completions and tasks.
+// Dates are always "2006-01-02" strings.
+type Store interface {
+	GetHabits() ([]Habit, error)
+	GetArchivedHabits() ([]Habit, error)
+	AddHabit(habit Habit) error
+	UpdateHabit(id string, habit Habit) error
+	ArchiveHabit(id string) error
+	UnarchiveHabit(id string) error
+	// DeleteHabit hides a habit from both lists but keeps its history, so
+	// RestoreHabit can bring it back until PurgeDeletedHabits runs.
+	DeleteHabit(id string) error
+	RestoreHabit(id string) error
+	GetDeletedHabits() ([]Habit, error)
+	// PurgeDeletedHabits permanently deletes the habits deleted before
+	// cutoff and returns them.
+	PurgeDeletedHabits(cutoff time.Time) ([]Habit, error)
+	DeleteHabitPermanently(id string) error
+
+	ToggleHabitCompletion(habitID, date string) error
+	IsHabitCompleted(habitID, date string) (bool, error)
+	// SetHabitValue records a quantitative habit's value for date. A value
+	// of zero or less removes the day's entry.
+	SetHabitValue(habitID, date string, value float64) error
+	// CompletionsBetween returns the habit's completions from from to to
+	// inclusive, oldest first.
+	CompletionsBetween(habitID, from, to string) ([]HabitCompletion, error)
+	// SetNote replaces the habit's note for date. Empty text removes it.
+	SetNote(habitID, date, text string) error
+	// NotesBetween returns the habit's notes from from to to inclusive,
+	// oldest first.
+	NotesBetween(habitID, from, to string) ([]DayNote, error)
+	// GetHabitStats covers the habit's entire history, not a fixed window.
+	GetHabitStats(habitID string) (StreakStats, error)
+	GetHabitStreak(habitID string) (int, error)
+	GetHabitLongestStreak(habitID string) (int, error)
+
+	AddTask(id, name, description, dueDate string) error
+	GetTasks() ([]Task, error)
+	// UpdateTask replaces the task's name, description and due date.
+	UpdateTask(id string, task Task) error
+	ToggleTask(id string) error
+	DeleteTask(id string) error
+
+	// GetHistory and SaveHistory keep History's undo and redo stacks
+	// between runs; see OpenHistory.
+	GetHistory() (undo, redo []Operation, err error)
+	SaveHistory(undo, redo []Operation) error
+
+	// Events returns the event log newest first: all of it, or only the
+	// events about the habit with habitID. A non-zero since stops it at
+	// the first event before that instant, and a limit above zero after
+	// that many events.
+	Events(habitID string, since time.Time, limit int) ([]Event, error)
+
+	// Clock is the clock the store was opened with.
+	Clock() Clock
+
+	Close() error
+}
@@ -0,0 +1,580 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runStoreTests is the conformance suite every Store implementation must pass.
+func runStoreTests(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Store)
+	}{
+		{"AddAndGetHabits", testAddAndGetHabits},
+		{"ToggleHabitCompletion", testToggleHabitCompletion},
+		{"DeleteHabit", testDeleteHabit},
+		{"AddAndToggleTask", testAddAndToggleTask},
+		{"DeleteTask", testDeleteTask},
+		{"UpdateTask", testUpdateTask},
+		{"UpdateHabit", testUpdateHabit},
+		{"GetHabitStreak", testGetHabitStreak},
+		{"GetHabitLongestStreak", testGetHabitLongestStreak},
+		{"ArchiveAndUnarchive", testArchiveAndUnarchive},
+		{"ArchiveMissingHabit", testArchiveMissingHabit},
+		{"DeletePermanentlyRemovesCompletions", testDeletePermanentlyRemovesCompletions},
+		{"SoftDeleteAndPurge", testSoftDeleteAndPurge},
+		{"ReturnedHabitsAreCopies", testReturnedHabitsAreCopies},
+		{"CompletionsBetween", testCompletionsBetween},
+		{"StatsCoverWholeHistory", testStatsCoverWholeHistory},
+		{"ScheduleChangeReindexes", testScheduleChangeReindexes},
+		{"RejectsInvalidSchedule", testRejectsInvalidSchedule},
+		{"DailyGoalStreak", testDailyGoalStreak},
+		{"WeeklyGoalStreak", testWeeklyGoalStreak},
+		{"QuitHabitCountsRelapses", testQuitHabitCountsRelapses},
+		{"CompletionsRecordLogTime", testCompletionsRecordLogTime},
+		{"DayNotes", testDayNotes},
+		{"SavesHistory", testSavesHistory},
+		{"EventsLogChanges", testEventsLogChanges},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	runStoreTests(t, func(t *testing.T) Store { return setupTestStore(t) })
+}
+
+func TestMemoryStore(t *testing.T) {
+	runStoreTests(t, func(*testing.T) Store { return NewMemoryStore(Clock{}) })
+}
+
+func testAddAndGetHabits(t *testing.T, s Store) {
+	if err := s.AddHabit(Habit{ID: "1", Name: "drink water", Type: "general"}); err != nil {
+		t.Fatalf("AddHabit err: %v", err)
+	}
+
+	habits, err := s.GetHabits()
+	if err != nil {
+		t.Fatalf("GetHabits err: %v", err)
+	}
+	if len(habits) != 1 || habits[0].Name != "drink water" {
+		t.Fatalf("unexpected habits: %+v", habits)
+	}
+}
+
+func testToggleHabitCompletion(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "exercise", Type: "general"})
+	date := s.Clock().Today().Format("2006-01-02")
+	if err := s.ToggleHabitCompletion("1", date); err != nil {
+		t.Fatalf("toggle: %v", err)
+	}
+	done, err := s.IsHabitCompleted("1", date)
+	if err != nil || !done {
+		t.Fatalf("completion not recorded")
+	}
+}
+
+func testDeleteHabit(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "read", Type: "general"})
+	if err := s.DeleteHabitPermanently("1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	habits, _ := s.GetHabits()
+	if len(habits) != 0 {
+		t.Fatalf("habit not deleted")
+	}
+}
+
+func testAddAndToggleTask(t *testing.T, s Store) {
+	if err := s.AddTask("1", "task", "", ""); err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	if err := s.ToggleTask("1"); err != nil {
+		t.Fatalf("toggle task: %v", err)
+	}
+	tasks, err := s.GetTasks()
+	if err != nil {
+		t.Fatalf("get tasks: %v", err)
+	}
+	if len(tasks) != 1 || !tasks[0].Completed {
+		t.Fatalf("task not toggled")
+	}
+}
+
+func testDeleteTask(t *testing.T, s Store) {
+	s.AddTask("1", "task", "", "")
+	if err := s.DeleteTask("1"); err != nil {
+		t.Fatalf("delete task: %v", err)
+	}
+	tasks, _ := s.GetTasks()
+	if len(tasks) != 0 {
+		t.Fatalf("task not deleted")
+	}
+}
+
+func testUpdateTask(t *testing.T, s Store) {
+	s.AddTask("1", "task", "", "")
+	s.ToggleTask("1")
+	if err := s.UpdateTask("1", Task{Name: "renamed", Description: "desc", DueDate: "2024-04-15"}); err != nil {
+		t.Fatalf("update task: %v", err)
+	}
+	tasks, _ := s.GetTasks()
+	if len(tasks) != 1 || tasks[0].Name != "renamed" || tasks[0].DueDate != "2024-04-15" || tasks[0].ID != "1" || !tasks[0].Completed || tasks[0].CreatedAt == "" {
+		t.Fatalf("expected only name, description and due date to change, got %+v", tasks)
+	}
+	if err := s.UpdateTask("nope", Task{Name: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdateHabit(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "meditate", Type: "daily"})
+	updatedHabit := Habit{
+		ID:    "1",
+		Name:  "meditate daily",
+		Type:  "daily",
+		Notes: map[string]string{"Monday": "10 minutes"},
+	}
+	if err := s.UpdateHabit("1", updatedHabit); err != nil {
+		t.Fatalf("update habit: %v", err)
+	}
+	habits, _ := s.GetHabits()
+	if len(habits) != 1 || habits[0].Name != "meditate daily" || habits[0].Notes["Monday"] != "10 minutes" {
+		t.Fatalf("habit not updated: %+v", habits[0])
+	}
+}
+
+func testGetHabitStreak(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "walk", Type: "general"})
+	today := s.Clock().Today()
+	s.ToggleHabitCompletion("1", today.Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -1).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -2).Format("2006-01-02"))
+
+	streak, err := s.GetHabitStreak("1")
+	if err != nil {
+		t.Fatalf("get streak: %v", err)
+	}
+	if streak != 3 {
+		t.Fatalf("expected streak 3, got %d", streak)
+	}
+
+	// test with a break in the streak
+	s.AddHabit(Habit{ID: "2", Name: "run", Type: "general"})
+	s.ToggleHabitCompletion("2", today.Format("2006-01-02"))
+	s.ToggleHabitCompletion("2", today.AddDate(0, 0, -2).Format("2006-01-02"))
+	streak, _ = s.GetHabitStreak("2")
+	if streak != 1 {
+		t.Fatalf("expected streak 1 after a break, got %d", streak)
+	}
+}
+
+func testGetHabitLongestStreak(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "code", Type: "general"})
+	today := s.Clock().Today()
+
+	// 5 day streak
+	s.ToggleHabitCompletion("1", today.Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -1).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -2).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -3).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -4).Format("2006-01-02"))
+
+	// 3 day streak
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -6).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -7).Format("2006-01-02"))
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -8).Format("2006-01-02"))
+
+	longest, err := s.GetHabitLongestStreak("1")
+	if err != nil {
+		t.Fatalf("get longest streak: %v", err)
+	}
+	if longest != 5 {
+		t.Fatalf("expected longest streak 5, got %d", longest)
+	}
+}
+
+func testArchiveAndUnarchive(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "journal", Type: "general"})
+	if err := s.ArchiveHabit("1"); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	habits, _ := s.GetHabits()
+	archived, _ := s.GetArchivedHabits()
+	if len(habits) != 0 || len(archived) != 1 {
+		t.Fatalf("expected 0 active and 1 archived, got %d and %d", len(habits), len(archived))
+	}
+	if err := s.UnarchiveHabit("1"); err != nil {
+		t.Fatalf("unarchive: %v", err)
+	}
+	habits, _ = s.GetHabits()
+	archived, _ = s.GetArchivedHabits()
+	if len(habits) != 1 || len(archived) != 0 {
+		t.Fatalf("expected 1 active and 0 archived, got %d and %d", len(habits), len(archived))
+	}
+}
+
+func testArchiveMissingHabit(t *testing.T, s Store) {
+	if err := s.ArchiveHabit("nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.UnarchiveHabit("nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testDeletePermanentlyRemovesCompletions(t *testing.T, s Store) {
+	date := s.Clock().Today().Format("2006-01-02")
+	s.AddHabit(Habit{ID: "1", Name: "floss", Type: "general"})
+	s.AddHabit(Habit{ID: "2", Name: "brush", Type: "general"})
+	s.ToggleHabitCompletion("1", date)
+	s.ToggleHabitCompletion("2", date)
+
+	if err := s.DeleteHabitPermanently("1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if done, _ := s.IsHabitCompleted("1", date); done {
+		t.Fatalf("completion of deleted habit survived")
+	}
+	if done, _ := s.IsHabitCompleted("2", date); !done {
+		t.Fatalf("completion of other habit was removed")
+	}
+}
+
+func testSoftDeleteAndPurge(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "journal", Type: "general"})
+	s.AddHabit(Habit{ID: "2", Name: "run", Type: "general"})
+	s.ArchiveHabit("1")
+	s.ToggleHabitCompletion("1", "2024-03-01")
+	if err := s.DeleteHabit("1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.DeleteHabit("nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	archived, _ := s.GetArchivedHabits()
+	deleted, _ := s.GetDeletedHabits()
+	if len(archived) != 0 || len(deleted) != 1 || deleted[0].DeletedAt == "" {
+		t.Fatalf("expected the habit to move to the deleted list, got %+v and %+v", archived, deleted)
+	}
+
+	if purged, err := s.PurgeDeletedHabits(time.Now().Add(-DeleteGracePeriod)); err != nil || len(purged) != 0 {
+		t.Fatalf("expected nothing purged within the grace period, got %+v, %v", purged, err)
+	}
+	if err := s.RestoreHabit("1"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	archived, _ = s.GetArchivedHabits()
+	if done, _ := s.IsHabitCompleted("1", "2024-03-01"); len(archived) != 1 || !done {
+		t.Fatalf("expected the habit back with its history, got %+v", archived)
+	}
+
+	s.DeleteHabit("1")
+	purged, err := s.PurgeDeletedHabits(time.Now().Add(time.Minute))
+	if err != nil || len(purged) != 1 || purged[0].ID != "1" {
+		t.Fatalf("expected the deleted habit purged, got %+v, %v", purged, err)
+	}
+	deleted, _ = s.GetDeletedHabits()
+	habits, _ := s.GetHabits()
+	if done, _ := s.IsHabitCompleted("1", "2024-03-01"); done || len(deleted) != 0 || len(habits) != 1 {
+		t.Fatalf("expected only the deleted habit gone, got deleted %+v, active %+v", deleted, habits)
+	}
+}
+
+func testReturnedHabitsAreCopies(t *testing.T, s Store) {
+	weekdays := []time.Weekday{time.Monday, time.Thursday}
+	s.AddHabit(Habit{ID: "1", Name: "sleep", Type: "general", Notes: map[string]string{"general": "8h"}, Schedule: Schedule{Kind: ScheduleWeekdays, Weekdays: weekdays}})
+	weekdays[0] = time.Sunday
+	habits, _ := s.GetHabits()
+	habits[0].Notes["general"] = "changed"
+	habits[0].Schedule.Weekdays[1] = time.Saturday
+
+	habits, _ = s.GetHabits()
+	if habits[0].Notes["general"] != "8h" {
+		t.Fatalf("store shares notes map with caller: %+v", habits[0])
+	}
+	if !slices.Equal(habits[0].Schedule.Weekdays, []time.Weekday{time.Monday, time.Thursday}) {
+		t.Fatalf("store shares weekdays with caller: %v", habits[0].Schedule.Weekdays)
+	}
+}
+
+func testCompletionsBetween(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "piano", Type: "general"})
+	s.AddHabit(Habit{ID: "2", Name: "guitar", Type: "general"})
+	for _, d := range []string{"2024-01-31", "2024-02-01", "2024-02-15", "2024-02-29", "2024-03-01"} {
+		s.ToggleHabitCompletion("1", d)
+	}
+	s.ToggleHabitCompletion("2", "2024-02-10")
+
+	got, err := s.CompletionsBetween("1", "2024-02-01", "2024-02-29")
+	if err != nil {
+		t.Fatalf("completions between: %v", err)
+	}
+	want := []string{"2024-02-01", "2024-02-15", "2024-02-29"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, got)
+	}
+	for i, c := range got {
+		if c.Date != want[i] || c.HabitID != "1" {
+			t.Fatalf("expected %v, got %+v", want, got)
+		}
+	}
+
+	none, err := s.CompletionsBetween("missing", "2024-01-01", "2024-12-31")
+	if err != nil || len(none) != 0 {
+		t.Fatalf("expected no completions for unknown habit, got %+v, %v", none, err)
+	}
+}
+
+func testStatsCoverWholeHistory(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "journal", Type: "general"})
+	today := s.Clock().Today()
+	for i := 0; i < 800; i++ {
+		s.ToggleHabitCompletion("1", today.AddDate(0, 0, -i).Format("2006-01-02"))
+	}
+
+	stats, err := s.GetHabitStats("1")
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Current != 800 || stats.Longest != 800 || stats.Total != 800 {
+		t.Fatalf("expected an 800 day streak, got %+v", stats)
+	}
+	if stats.LongestStart != today.AddDate(0, 0, -799).Format("2006-01-02") || stats.LongestEnd != today.Format("2006-01-02") {
+		t.Fatalf("unexpected longest range: %+v", stats)
+	}
+
+	// breaking the run in the middle splits it
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -500).Format("2006-01-02"))
+	stats, _ = s.GetHabitStats("1")
+	if stats.Current != 500 || stats.Longest != 500 || stats.Total != 799 {
+		t.Fatalf("unexpected stats after a break: %+v", stats)
+	}
+}
+
+func testScheduleChangeReindexes(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "gym"})
+	// 2024-01-01 was a Monday
+	for _, d := range []string{"2024-01-01", "2024-01-03", "2024-01-05"} {
+		s.ToggleHabitCompletion("1", d)
+	}
+	stats, _ := s.GetHabitStats("1")
+	if stats.Longest != 1 {
+		t.Fatalf("expected daily longest 1, got %+v", stats)
+	}
+
+	mwf, _ := ParseSchedule("mon,wed,fri", s.Clock().Today())
+	if err := s.UpdateHabit("1", Habit{ID: "1", Name: "gym", Schedule: mwf}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	stats, _ = s.GetHabitStats("1")
+	if stats.Longest != 3 || stats.LongestStart != "2024-01-01" || stats.LongestEnd != "2024-01-05" {
+		t.Fatalf("expected mon/wed/fri longest 3, got %+v", stats)
+	}
+
+	// toggles after the change use the new schedule
+	s.ToggleHabitCompletion("1", "2024-01-08")
+	stats, _ = s.GetHabitStats("1")
+	if stats.Longest != 4 || stats.Total != 4 {
+		t.Fatalf("expected longest 4 after toggle, got %+v", stats)
+	}
+}
+
+func testRejectsInvalidSchedule(t *testing.T, s Store) {
+	bad := Habit{ID: "1", Name: "gym", Schedule: Schedule{Kind: ScheduleWeekly, Times: 9}}
+	if err := s.AddHabit(bad); err == nil {
+		t.Fatalf("expected invalid schedule to be rejected")
+	}
+}
+
+func testDailyGoalStreak(t *testing.T, s Store) {
+	water, _ := ParseGoal("8 glasses/day")
+	s.AddHabit(Habit{ID: "1", Name: "water", Goal: water})
+	s.SetHabitValue("1", "2024-01-01", 8)
+	s.SetHabitValue("1", "2024-01-02", 5)
+	s.SetHabitValue("1", "2024-01-03", 9)
+
+	got, _ := s.CompletionsBetween("1", "2024-01-02", "2024-01-02")
+	if len(got) != 1 || got[0].Value != 5 {
+		t.Fatalf("expected value 5 on 2024-01-02, got %+v", got)
+	}
+	stats, _ := s.GetHabitStats("1")
+	if stats.Longest != 1 || stats.Total != 3 {
+		t.Fatalf("a day under target should break the streak, got %+v", stats)
+	}
+
+	s.SetHabitValue("1", "2024-01-02", 8)
+	stats, _ = s.GetHabitStats("1")
+	if stats.Longest != 3 || stats.Total != 3 {
+		t.Fatalf("expected 3 day streak once the target is met, got %+v", stats)
+	}
+
+	s.SetHabitValue("1", "2024-01-02", 0)
+	stats, _ = s.GetHabitStats("1")
+	if stats.Longest != 1 || stats.Total != 2 {
+		t.Fatalf("expected zero to clear the day, got %+v", stats)
+	}
+
+	if err := s.SetHabitValue("1", "2024-01-01", math.NaN()); err == nil {
+		t.Fatal("expected NaN to be rejected")
+	}
+	if got, _ := s.CompletionsBetween("1", "2024-01-01", "2024-01-01"); len(got) != 1 || got[0].Value != 8 {
+		t.Fatalf("expected a rejected value to leave the day alone, got %+v", got)
+	}
+}
+
+func testWeeklyGoalStreak(t *testing.T, s Store) {
+	run, _ := ParseGoal("20 km/week")
+	s.AddHabit(Habit{ID: "1", Name: "run", Goal: run})
+	// week of Sun 2023-12-31, then week of Sun 2024-01-07
+	s.SetHabitValue("1", "2024-01-01", 10)
+	s.SetHabitValue("1", "2024-01-04", 12)
+	s.SetHabitValue("1", "2024-01-08", 15)
+
+	stats, _ := s.GetHabitStats("1")
+	if stats.Longest != 1 || stats.Unit != "week" || stats.LongestStart != "2023-12-31" {
+		t.Fatalf("expected one met week, got %+v", stats)
+	}
+
+	s.SetHabitValue("1", "2024-01-10", 5)
+	stats, _ = s.GetHabitStats("1")
+	if stats.Longest != 2 {
+		t.Fatalf("expected two met weeks, got %+v", stats)
+	}
+}
+
+func testQuitHabitCountsRelapses(t *testing.T, s Store) {
+	today := s.Clock().Today()
+	created := today.AddDate(0, 0, -20).Format("2006-01-02")
+	s.AddHabit(Habit{ID: "1", Name: "smoking", Kind: KindQuit, CreatedAt: created})
+
+	stats, _ := s.GetHabitStats("1")
+	if stats.Current != 21 || stats.Total != 0 {
+		t.Fatalf("expected 21 clean days and no relapses, got %+v", stats)
+	}
+
+	s.ToggleHabitCompletion("1", today.AddDate(0, 0, -5).Format("2006-01-02"))
+	stats, _ = s.GetHabitStats("1")
+	if stats.Current != 5 || stats.Longest != 15 || stats.Total != 1 {
+		t.Fatalf("expected 5 clean after a relapse, best 15, got %+v", stats)
+	}
+}
+
+func testCompletionsRecordLogTime(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "read"})
+	s.ToggleHabitCompletion("1", "2024-03-01")
+	completions, _ := s.CompletionsBetween("1", "2024-03-01", "2024-03-01")
+	if len(completions) != 1 || completions[0].LoggedAt == "" {
+		t.Fatalf("expected a logged-at timestamp, got %+v", completions)
+	}
+}
+
+func testDayNotes(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "run"})
+	s.SetNote("1", "2024-03-04", "felt slow")
+	s.SetNote("1", "2024-03-11", "new shoes")
+	s.SetNote("1", "2024-03-04", "felt slow, windy")
+
+	notes, err := s.NotesBetween("1", "2024-03-01", "2024-03-31")
+	if err != nil {
+		t.Fatalf("NotesBetween err: %v", err)
+	}
+	if len(notes) != 2 || notes[0].Text != "felt slow, windy" || notes[1].Date != "2024-03-11" {
+		t.Fatalf("expected one note per date, oldest first, got %+v", notes)
+	}
+	if done, _ := s.IsHabitCompleted("1", "2024-03-04"); done {
+		t.Fatalf("a note alone should not complete the day")
+	}
+
+	s.SetNote("1", "2024-03-11", "")
+	if notes, _ := s.NotesBetween("1", "2024-03-11", "2024-03-11"); len(notes) != 0 {
+		t.Fatalf("expected empty text to remove the note, got %+v", notes)
+	}
+
+	s.DeleteHabitPermanently("1")
+	if notes, _ := s.NotesBetween("1", "", "9999-12-31"); len(notes) != 0 {
+		t.Fatalf("expected notes deleted with the habit, got %+v", notes)
+	}
+}
+
+func testSavesHistory(t *testing.T, s Store) {
+	if undo, redo, err := s.GetHistory(); err != nil || len(undo) != 0 || len(redo) != 0 {
+		t.Fatalf("expected no history yet, got %v %v %v", undo, redo, err)
+	}
+	op := Operation{Summary: `toggled "read" on 2024-03-01`, Completion: &Change[HabitCompletion]{
+		After: &HabitCompletion{HabitID: "1", Date: "2024-03-01"},
+	}}
+	if err := s.SaveHistory([]Operation{op}, nil); err != nil {
+		t.Fatalf("save history: %v", err)
+	}
+	undo, redo, err := s.GetHistory()
+	if err != nil || len(undo) != 1 || len(redo) != 0 || undo[0].Summary != op.Summary || undo[0].Completion.After.Date != "2024-03-01" {
+		t.Fatalf("unexpected history %+v %+v %v", undo, redo, err)
+	}
+}
+
+func testEventsLogChanges(t *testing.T, s Store) {
+	s.AddHabit(Habit{ID: "1", Name: "read"})
+	s.AddHabit(Habit{ID: "2", Name: "run"})
+	s.ToggleHabitCompletion("1", "2024-03-01")
+	s.ToggleHabitCompletion("1", "2024-03-01")
+	s.ArchiveHabit("1")
+	s.SetHabitValue("2", "2024-03-01", 0) // changes nothing
+	s.AddTask("t", "call mum", "", "")
+
+	events, err := s.Events("1", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	want := []string{EventHabitArchive, EventCompletionToggle, EventCompletionToggle, EventHabitCreate}
+	if !slices.Equal(actions, want) {
+		t.Fatalf("expected %v, got %v", want, actions)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Seq >= events[i-1].Seq {
+			t.Fatalf("expected events newest first, got %+v", events)
+		}
+	}
+	if on := events[2]; on.Name != "read" || on.Date != "2024-03-01" || !strings.HasSuffix(on.At, "Z") || !strings.Contains(string(on.Payload), `"date":"2024-03-01"`) {
+		t.Fatalf("unexpected toggle event %+v (%s)", on, on.Payload)
+	}
+	if off := events[1]; string(off.Payload) != "null" {
+		t.Fatalf("expected a null payload once the completion is gone, got %s", off.Payload)
+	}
+	if archived := events[0]; !strings.Contains(string(archived.Payload), `"archived":true`) {
+		t.Fatalf("expected the archived habit as payload, got %s", archived.Payload)
+	}
+
+	all, err := s.Events("", time.Time{}, 0)
+	if err != nil || len(all) != 6 || all[0].Action != EventTaskCreate || all[0].TaskID != "t" {
+		t.Fatalf("expected every event, starting with the task, got %+v %v", all, err)
+	}
+	if latest, _ := s.Events("", time.Time{}, 2); len(latest) != 2 || latest[0].Seq != all[0].Seq {
+		t.Fatalf("expected the two latest events, got %+v", latest)
+	}
+	if recent, _ := s.Events("", time.Now().Add(-time.Hour), 0); len(recent) != 6 {
+		t.Fatalf("expected every event from the last hour, got %+v", recent)
+	}
+	if future, _ := s.Events("", time.Now().Add(time.Hour), 0); len(future) != 0 {
+		t.Fatalf("expected nothing since an hour from now, got %+v", future)
+	}
+
+	s.SetNote("2", "2024-03-01", "") // changes nothing
+	s.SetNote("2", "2024-03-01", "rainy")
+	s.SetNote("2", "2024-03-01", "rainy") // changes nothing
+	if notes, _ := s.Events("2", time.Time{}, 0); len(notes) != 2 || notes[0].Action != EventNoteSet {
+		t.Fatalf("expected one note event on top of the habit's creation, got %+v", notes)
+	}
+}
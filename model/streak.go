@@ -0,0 +1,274 @@
+// File: model/streak.go
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// StreakStats summarizes a habit's whole completion history. Current and
+// Longest count periods of the habit's schedule, named by Unit.
+//
+// For quit habits the runs are inverted: Current is days clean since the
+// last relapse, Longest the longest clean run and Total the relapse count.
+type StreakStats struct {
+	Current      int     `json:"current"`
+	Longest      int     `json:"longest"`
+	LongestStart string  `json:"longest_start,omitempty"`
+	LongestEnd   string  `json:"longest_end,omitempty"`
+	Total        int     `json:"total"`
+	Unit         string  `json:"unit"`
+	LastRelapse  string  `json:"last_relapse,omitempty"`
+	RelapseRate  float64 `json:"relapses_per_30_days,omitempty"`
+}
+
+// streakRun is an inclusive range of consecutive met periods (see Schedule).
+type streakRun struct {
+	Start int `json:"s"`
+	End   int `json:"e"`
+}
+
+// streakIndex is the cached form of a habit's history: its met periods
+// collapsed into sorted, non-touching runs. Toggling a day only rechecks
+// that day's period and splits or merges the runs around it, so stats never
+// need a full history scan.
+type streakIndex struct {
+	Runs  []streakRun `json:"runs"`
+	Total int         `json:"total"`
+}
+
+// dayNumber converts a "2006-01-02" date into days since the Unix epoch.
+func dayNumber(date string) (int, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Unix() / 86400), nil
+}
+
+func dayDate(n int) string {
+	return time.Unix(int64(n)*86400, 0).UTC().Format("2006-01-02")
+}
+
+// streakSchedule is the schedule whose periods h's streaks count. A weekly
+// goal is judged a week at a time whatever the schedule says.
+func (h Habit) streakSchedule() Schedule {
+	if h.IsQuit() {
+		return Schedule{}
+	}
+	if h.Goal != nil && h.Goal.Per == "week" {
+		return Schedule{Kind: ScheduleWeekly, Times: 1}
+	}
+	return h.Schedule
+}
+
+// QuotaPeriod returns the first and last date of the week or month
+// holding date, for habits due a number of times per week or month
+// rather than on set days; ok is false for the rest. Weeks start on
+// weekStart.
+func (h Habit) QuotaPeriod(date string, weekStart time.Weekday) (from, to string, ok bool) {
+	sched := h.streakSchedule()
+	if k := sched.kind(); k != ScheduleWeekly && k != ScheduleMonthly {
+		return "", "", false
+	}
+	day, err := dayNumber(date)
+	if err != nil {
+		return "", "", false
+	}
+	p, _ := sched.period(day, weekStart)
+	first, last := sched.periodRange(p, weekStart)
+	return dayDate(first), dayDate(last), true
+}
+
+// PeriodMet reports whether completions, all inside one period of
+// h.streakSchedule(), satisfy the habit.
+func (h Habit) PeriodMet(completions []HabitCompletion) bool {
+	sched := h.streakSchedule()
+	switch {
+	case h.IsQuit() || h.Goal == nil:
+		return len(completions) >= sched.required()
+	case h.Goal.Per == "week":
+		return h.Goal.Aggregate(completions) >= h.Goal.Target
+	default:
+		n := 0
+		for _, c := range completions {
+			if c.Value >= h.Goal.Target {
+				n++
+			}
+		}
+		return n >= sched.required()
+	}
+}
+
+// sameStreakRules reports whether h and o would index history identically.
+func (h Habit) sameStreakRules(o Habit) bool {
+	return h.Kind == o.Kind && h.Schedule.Equal(o.Schedule) && h.Goal.Equal(o.Goal)
+}
+
+// buildStreakIndex indexes completions, which must be sorted by date,
+// against h's schedule and goal, with weeks starting on weekStart.
+func buildStreakIndex(h Habit, completions []HabitCompletion, weekStart time.Weekday) (*streakIndex, error) {
+	x := &streakIndex{Total: len(completions)}
+	sched := h.streakSchedule()
+	periods := make(map[int][]HabitCompletion)
+	for _, c := range completions {
+		day, err := dayNumber(c.Date)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := sched.period(day, weekStart); ok {
+			periods[p] = append(periods[p], c)
+		}
+	}
+	for p, cs := range periods {
+		if h.PeriodMet(cs) {
+			x.add(p)
+		}
+	}
+	return x, nil
+}
+
+// update rechecks the period containing day after a change to it. load
+// returns the completions between two dates inclusive, oldest first.
+func (x *streakIndex) update(h Habit, day int, weekStart time.Weekday, load func(from, to string) ([]HabitCompletion, error)) error {
+	sched := h.streakSchedule()
+	p, ok := sched.period(day, weekStart)
+	if !ok {
+		return nil
+	}
+	from, to := sched.periodRange(p, weekStart)
+	completions, err := load(dayDate(from), dayDate(to))
+	if err != nil {
+		return err
+	}
+	if h.PeriodMet(completions) {
+		x.add(p)
+	} else {
+		x.remove(p)
+	}
+	return nil
+}
+
+// find returns the index of the run containing period p, or -1, and the
+// index at which a run starting at p would be inserted.
+func (x *streakIndex) find(p int) (int, int) {
+	i := sort.Search(len(x.Runs), func(i int) bool { return x.Runs[i].Start > p })
+	if i > 0 && x.Runs[i-1].End >= p {
+		return i - 1, i
+	}
+	return -1, i
+}
+
+func (x *streakIndex) add(p int) {
+	found, i := x.find(p)
+	if found >= 0 {
+		return
+	}
+	joinsLeft := i > 0 && x.Runs[i-1].End == p-1
+	joinsRight := i < len(x.Runs) && x.Runs[i].Start == p+1
+	switch {
+	case joinsLeft && joinsRight:
+		x.Runs[i-1].End = x.Runs[i].End
+		x.Runs = append(x.Runs[:i], x.Runs[i+1:]...)
+	case joinsLeft:
+		x.Runs[i-1].End = p
+	case joinsRight:
+		x.Runs[i].Start = p
+	default:
+		x.Runs = append(x.Runs, streakRun{})
+		copy(x.Runs[i+1:], x.Runs[i:])
+		x.Runs[i] = streakRun{Start: p, End: p}
+	}
+}
+
+func (x *streakIndex) remove(p int) {
+	found, _ := x.find(p)
+	if found < 0 {
+		return
+	}
+	r := x.Runs[found]
+	switch {
+	case r.Start == p && r.End == p:
+		x.Runs = append(x.Runs[:found], x.Runs[found+1:]...)
+	case r.Start == p:
+		x.Runs[found].Start++
+	case r.End == p:
+		x.Runs[found].End--
+	default:
+		x.Runs[found].End = p - 1
+		x.Runs = append(x.Runs, streakRun{})
+		copy(x.Runs[found+2:], x.Runs[found+1:])
+		x.Runs[found+1] = streakRun{Start: p + 1, End: r.End}
+	}
+}
+
+// stats reports the streaks as of today. A period that is still in progress
+// doesn't break the current streak until it ends unmet. The most recent run
+// wins ties for longest.
+func (x *streakIndex) stats(h Habit, today int, weekStart time.Weekday) StreakStats {
+	if h.IsQuit() {
+		return x.quitStats(h, today)
+	}
+	sched := h.streakSchedule()
+	st := StreakStats{Total: x.Total, Unit: sched.Unit()}
+	cur := sched.periodAtOrBefore(today, weekStart)
+	_, curEnd := sched.periodRange(cur, weekStart)
+	inProgress := curEnd >= today
+	for _, r := range x.Runs {
+		if r.Start > cur {
+			break
+		}
+		end := min(r.End, cur)
+		n := end - r.Start + 1
+		if n >= st.Longest {
+			first, _ := sched.periodRange(r.Start, weekStart)
+			_, last := sched.periodRange(end, weekStart)
+			st.Longest = n
+			st.LongestStart = dayDate(first)
+			st.LongestEnd = dayDate(min(last, today))
+		}
+		if end == cur || (end == cur-1 && inProgress) {
+			st.Current = n
+		}
+	}
+	return st
+}
+
+// quitStats reads a quit habit's index, whose runs are relapse days, for
+// the clean stretches between them. Tracking starts at the habit's
+// CreatedAt, or at the first relapse for habits that predate it.
+func (x *streakIndex) quitStats(h Habit, today int) StreakStats {
+	st := StreakStats{Unit: "day"}
+	start, err := dayNumber(h.CreatedAt)
+	if err != nil {
+		start = today
+	}
+	if len(x.Runs) > 0 {
+		start = min(start, x.Runs[0].Start)
+	}
+
+	clean := func(from, to int) {
+		if n := to - from + 1; n > 0 && n >= st.Longest {
+			st.Longest = n
+			st.LongestStart = dayDate(from)
+			st.LongestEnd = dayDate(to)
+		}
+	}
+	next := start
+	for _, r := range x.Runs {
+		if r.Start > today {
+			break
+		}
+		clean(next, r.Start-1)
+		next = r.End + 1
+		st.Total += min(r.End, today) - r.Start + 1
+		st.LastRelapse = dayDate(min(r.End, today))
+	}
+	clean(next, today)
+	st.Current = max(today-next+1, 0)
+
+	if tracked := today - start + 1; tracked > 0 {
+		st.RelapseRate = float64(st.Total) * 30 / float64(tracked)
+	}
+	return st
+}
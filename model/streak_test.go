@@ -0,0 +1,114 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreakIndexMergesAndSplits(t *testing.T) {
+	x := &streakIndex{}
+	for _, day := range []int{10, 12, 11, 20, 19, 11} {
+		x.add(day)
+	}
+	want := []streakRun{{10, 12}, {19, 20}}
+	if !reflect.DeepEqual(x.Runs, want) {
+		t.Fatalf("expected %v, got %v", want, x.Runs)
+	}
+
+	x.remove(11)
+	x.remove(20)
+	x.remove(30)
+	want = []streakRun{{10, 10}, {12, 12}, {19, 19}}
+	if !reflect.DeepEqual(x.Runs, want) {
+		t.Fatalf("expected %v, got %v", want, x.Runs)
+	}
+}
+
+func TestStreakIndexStats(t *testing.T) {
+	x, err := buildStreakIndex(Habit{}, []HabitCompletion{
+		{Date: "2024-01-01"}, {Date: "2024-01-02"}, {Date: "2024-01-03"},
+		{Date: "2024-01-05"}, {Date: "2024-01-06"},
+	}, time.Sunday)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	today, _ := dayNumber("2024-01-06")
+	got := x.stats(Habit{}, today, time.Sunday)
+	want := StreakStats{Current: 2, Longest: 3, LongestStart: "2024-01-01", LongestEnd: "2024-01-03", Total: 5, Unit: "day"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// days after "today" don't count towards streaks yet
+	today, _ = dayNumber("2024-01-02")
+	got = x.stats(Habit{}, today, time.Sunday)
+	if got.Current != 2 || got.Longest != 2 || got.LongestEnd != "2024-01-02" {
+		t.Fatalf("unexpected stats as of 2024-01-02: %+v", got)
+	}
+}
+
+func TestQuotaPeriod(t *testing.T) {
+	tests := []struct {
+		habit    Habit
+		date     string
+		from, to string
+		ok       bool
+	}{
+		{Habit{Schedule: Schedule{Kind: ScheduleWeekly, Times: 3}}, "2024-03-14", "2024-03-10", "2024-03-16", true},
+		{Habit{Schedule: Schedule{Kind: ScheduleMonthly, Times: 2}}, "2024-02-29", "2024-02-01", "2024-02-29", true},
+		{Habit{Goal: &Goal{Unit: "km", Target: 20, Per: "week"}}, "2024-03-14", "2024-03-10", "2024-03-16", true},
+		{Habit{Schedule: Schedule{Kind: ScheduleWeekly, Times: 3}, Kind: KindQuit}, "2024-03-14", "", "", false},
+		{Habit{}, "2024-03-14", "", "", false},
+	}
+	for _, tt := range tests {
+		from, to, ok := tt.habit.QuotaPeriod(tt.date, time.Sunday)
+		if from != tt.from || to != tt.to || ok != tt.ok {
+			t.Errorf("%+v on %s: got %s..%s %v, want %s..%s %v", tt.habit, tt.date, from, to, ok, tt.from, tt.to, tt.ok)
+		}
+	}
+}
+
+func TestStreakIndexInProgressPeriod(t *testing.T) {
+	x, _ := buildStreakIndex(Habit{}, []HabitCompletion{{Date: "2024-01-04"}, {Date: "2024-01-05"}}, time.Sunday)
+
+	// today isn't over yet, so yesterday's streak still counts
+	today, _ := dayNumber("2024-01-06")
+	if got := x.stats(Habit{}, today, time.Sunday); got.Current != 2 {
+		t.Fatalf("expected current 2 while today is open, got %+v", got)
+	}
+	today, _ = dayNumber("2024-01-07")
+	if got := x.stats(Habit{}, today, time.Sunday); got.Current != 0 {
+		t.Fatalf("expected current 0 after a missed day, got %+v", got)
+	}
+}
+
+func TestQuitHabitStats(t *testing.T) {
+	h := Habit{Kind: KindQuit, CreatedAt: "2024-01-01"}
+	x, _ := buildStreakIndex(h, []HabitCompletion{
+		{Date: "2024-01-05"}, {Date: "2024-01-06"}, {Date: "2024-01-20"},
+	}, time.Sunday)
+
+	today, _ := dayNumber("2024-01-30")
+	got := x.stats(h, today, time.Sunday)
+	want := StreakStats{
+		Current:      10,
+		Longest:      13,
+		LongestStart: "2024-01-07",
+		LongestEnd:   "2024-01-19",
+		Total:        3,
+		Unit:         "day",
+		LastRelapse:  "2024-01-20",
+		RelapseRate:  3,
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// a relapse today means zero days clean
+	today, _ = dayNumber("2024-01-20")
+	if got := x.stats(h, today, time.Sunday); got.Current != 0 || got.Total != 3 {
+		t.Fatalf("unexpected stats on relapse day: %+v", got)
+	}
+}
@@ -2,225 +2,512 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"habit-tracker/config"
 	"habit-tracker/model"
+	"log"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	dayStyle       = lipgloss.NewStyle().Padding(1, 2).Border(lipgloss.RoundedBorder())
-	highlightedDay = dayStyle.BorderForeground(lipgloss.Color("2"))
-	selectedDay    = dayStyle.Bold(true).Underline(true)
+	dayStyle       lipgloss.Style
+	highlightedDay lipgloss.Style
+	selectedDay    lipgloss.Style
+
+	completedHabitStyle  lipgloss.Style
+	incompleteHabitStyle lipgloss.Style
+	selectedHabitStyle   lipgloss.Style
+	habitSectionStyle    lipgloss.Style
+	controlsStyle        lipgloss.Style
+	overdueTaskStyle     lipgloss.Style
+	statusStyle          lipgloss.Style
+	errorStatusStyle     lipgloss.Style
+
+	calendarCursorStyle lipgloss.Style
+	calendarTodayStyle  lipgloss.Style
+	calendarFutureStyle lipgloss.Style
+)
+
+func init() {
+	applyColors(config.Default().Colors)
+}
+
+// applyColors builds the styles above from the configured colors.
+func applyColors(c config.Colors) {
+	dayStyle = lipgloss.NewStyle().Padding(1, 2).Border(lipgloss.RoundedBorder())
+	highlightedDay = dayStyle.BorderForeground(lipgloss.Color(c.Today))
+	selectedDay = dayStyle.Bold(true).Underline(true).BorderForeground(lipgloss.Color(c.SelectedBackground))
 
 	completedHabitStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("10")).
-				Background(lipgloss.Color("22")).
-				Padding(0, 1).
-				Bold(true)
+		Foreground(lipgloss.Color(c.Completed)).
+		Background(lipgloss.Color(c.CompletedBackground)).
+		Padding(0, 1).
+		Bold(true)
 
 	incompleteHabitStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("8")).
-				Background(lipgloss.Color("0")).
-				Padding(0, 1)
+		Foreground(lipgloss.Color(c.Incomplete)).
+		Background(lipgloss.Color(c.IncompleteBackground)).
+		Padding(0, 1)
 
 	selectedHabitStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("15")).
-				Background(lipgloss.Color("4")).
-				Padding(0, 1).
-				Bold(true)
+		Foreground(lipgloss.Color(c.Selected)).
+		Background(lipgloss.Color(c.SelectedBackground)).
+		Padding(0, 1).
+		Bold(true)
 
 	habitSectionStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("6")).
-				Padding(1).
-				MarginTop(1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(c.Border)).
+		Padding(1).
+		MarginTop(1)
+
+	overdueTaskStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(c.Overdue)).
+		Padding(0, 1)
+
+	calendarCursorStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(c.Selected)).
+		Background(lipgloss.Color(c.SelectedBackground)).
+		Bold(true)
+	calendarTodayStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(c.Today)).Bold(true)
+	calendarFutureStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(c.Incomplete))
+
+	statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(c.Completed))
+	errorStatusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(c.Error)).Bold(true)
 
 	controlsStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("7")).
-			Italic(true).
-			MarginTop(1)
-)
+		Foreground(lipgloss.Color(c.Controls)).
+		Italic(true).
+		MarginTop(1)
+}
 
-// keybindings for application commands
-var keys = struct {
-	Left, Right, Up, Down, Tab, Enter, Escape, Backspace, Space,
-	N, E, A, D, C, U, V, Quit key.Binding
-}{
-	Left:      key.NewBinding(key.WithKeys("left"), key.WithHelp("←", "prev")),
-	Right:     key.NewBinding(key.WithKeys("right"), key.WithHelp("→", "next")),
-	Up:        key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "move up")),
-	Down:      key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "move down")),
-	Tab:       key.NewBinding(key.WithKeys("tab"), key.WithHelp("⇥", "switch section")),
-	Enter:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("⏎", "confirm")),
-	Escape:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel/back")),
-	Backspace: key.NewBinding(key.WithKeys("backspace"), key.WithHelp("⌫", "delete char")),
-	Space:     key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle")),
-	N:         key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "toggle notes")),
-	E:         key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit")),
-	A:         key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "add")),
-	D:         key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "archive")),
-	C:         key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "calendar view")),
-	U:         key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "unarchive")),
-	V:         key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "view archived")),
-	Quit:      key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+// keyMap holds the bindings for application commands.
+type keyMap struct {
+	Left, Right, PrevWeek, NextWeek, Today, Up, Down, Tab, Enter, Escape, Backspace, Space,
+	Increment, Decrement, Note, Edit, Add, Archive, Calendar, Unarchive, Archived, Delete, Undo, Redo, Help, Quit key.Binding
+	// ArchivedUndo is Undo without the keys it shares with Unarchive,
+	// which unarchive in the archived tab.
+	ArchivedUndo key.Binding
+}
+
+// keys is built from the configured bindings by StartApp.
+var keys = newKeyMap(config.Default().KeyMap())
+
+// newKeyMap binds each action to its keys from config.Config.KeyMap, with
+// help showing those keys.
+func newKeyMap(bound map[string][]string) keyMap {
+	bindKeys := func(ks []string, help string) key.Binding {
+		return key.NewBinding(key.WithKeys(ks...), key.WithHelp(keyLabel(ks), help))
+	}
+	binding := func(action, help string) key.Binding {
+		return bindKeys(bound[action], help)
+	}
+	archivedUndo := slices.DeleteFunc(slices.Clone(bound["undo"]), func(k string) bool {
+		return slices.Contains(bound["unarchive"], k)
+	})
+	return keyMap{
+		Left:      binding("left", "prev"),
+		Right:     binding("right", "next"),
+		PrevWeek:  binding("prev_week", "previous week"),
+		NextWeek:  binding("next_week", "next week"),
+		Today:     binding("today", "jump to today"),
+		Up:        binding("up", "move up"),
+		Down:      binding("down", "move down"),
+		Tab:       binding("next_view", "switch section"),
+		Enter:     binding("confirm", "confirm"),
+		Escape:    binding("cancel", "cancel/back"),
+		Backspace: binding("delete_char", "delete char"),
+		Space:     binding("toggle", "toggle / enter value"),
+		Increment: binding("increment", "increase value"),
+		Decrement: binding("decrement", "decrease value"),
+		Note:      binding("note", "note for the day"),
+		Edit:      binding("edit", "edit"),
+		Add:       binding("add", "add"),
+		Archive:   binding("archive", "archive"),
+		Calendar:  binding("calendar", "calendar view"),
+		Unarchive: binding("unarchive", "unarchive"),
+		Archived:  binding("archived", "view archived"),
+		Delete:    binding("delete", "delete"),
+		Undo:      binding("undo", "undo"),
+		Redo:      binding("redo", "redo"),
+		Help:      binding("help", "all keys"),
+		Quit:      binding("quit", "quit"),
+
+		ArchivedUndo: bindKeys(archivedUndo, "undo"),
+	}
+}
+
+var keySymbols = map[string]string{
+	"left": "←", "right": "→", "up": "↑", "down": "↓",
+	"tab": "⇥", "enter": "⏎", "backspace": "⌫", " ": "space",
+}
+
+// keyLabel is how help shows a binding's keys, e.g. "k/↑".
+func keyLabel(ks []string) string {
+	labels := make([]string, len(ks))
+	for i, k := range ks {
+		labels[i] = k
+		if symbol, ok := keySymbols[k]; ok {
+			labels[i] = symbol
+		}
+	}
+	return strings.Join(labels, "/")
 }
 
 type modelState struct {
-	selected            int
-	today               int
-	dates               []time.Time
-	habits              []model.Habit
-	archivedHabits      []model.Habit
-	tasks               []model.Task
-	selectedHabit       int
-	selectedTask        int
-	selectedArchived    int
-	mode                string // week, habits, tasks, stats, archived, adding_habit, editing_habit, calendar
-	newHabitName        string
-	newHabitDescription string
-	newTaskName         string
-	showNotes           bool
-	editingNote         bool
-	editedNote          string
-	newHabitType        string
-	calendarMonth       time.Time
-	editingField        string // "name" or "description"
+	store            model.Store // history, so every change can be undone
+	history          *model.History
+	selected         int
+	cfg              config.Config
+	today            int // index into dates
+	dates            []time.Time
+	habits           []model.Habit
+	archivedHabits   []model.Habit
+	tasks            []model.Task
+	selectedHabit    int
+	selectedTask     int
+	selectedArchived int
+	mode             string // week, habits, tasks, stats, archived, adding_habit, editing_habit, calendar
+	taskField        string // "name", "description" or "due"
+	taskNameInput    textinput.Model
+	taskDescInput    textinput.Model
+	taskDueInput     textinput.Model
+	editingNote      bool
+	noteDate         time.Time // the day the note being edited belongs to
+	noteInput        textarea.Model
+	newHabitType     string
+	newHabitKind     string    // model.KindBuild or model.KindQuit
+	calendarDay      time.Time // the calendar's cursor
+	editingField     string    // "name", "schedule", "goal" or "description"
+	nameInput        textinput.Model
+	scheduleInput    textinput.Model
+	goalInput        textinput.Model
+	descriptionInput textinput.Model
+	formError        string
+	status           string // the status line, see notify and fail
+	statusIsError    bool
+	statusID         int
+	errorLog         *log.Logger // gets every error fail reports; nil for none
+	valueInput       string
+	deleteCount      int    // completions of the archived habit about to be deleted; -1 until counted
+	pendingTask      string // the task to select once the lists arrive with it
+	help             help.Model
+	showHelp         bool // the ? overlay listing every key
+	// What View shows from the store, see refresh.
+	week      weekData
+	stats     map[string]habitStats
+	calendar  calendarData
+	loadID    int  // the latest refresh
+	loading   int  // its loads still running
+	listsID   int  // the latest reload
+	reloading bool // its lists are still being read
+	spinner   spinner.Model
 }
 
-func initialModel() modelState {
-	today := time.Now()
-	start := today.AddDate(0, 0, -int(today.Weekday()))
+func initialModel(store model.Store, cfg config.Config) modelState {
+	today := store.Clock().Today()
+	start := store.Clock().StartOfWeek(today)
+	todayIndex := int(today.Sub(start).Hours() / 24)
 	week := make([]time.Time, 7)
 	for i := 0; i < 7; i++ {
 		week[i] = start.AddDate(0, 0, i)
 	}
-	habits, _ := model.GetHabits()
-	archivedHabits, _ := model.GetArchivedHabits()
-	tasks, _ := model.GetTasks()
-	return modelState{
-		today:          int(today.Weekday()),
-		selected:       int(today.Weekday()),
-		dates:          week,
-		habits:         habits,
-		archivedHabits: archivedHabits,
-		tasks:          tasks,
-		selectedHabit:  0,
-		selectedTask:   0,
-		mode:           "week",
-		calendarMonth:  today,
+	history, historyErr := model.OpenHistory(store)
+	if historyErr != nil {
+		history = model.NewHistory(store) // undo still works for this session
+	}
+	m := modelState{
+		store:            history,
+		history:          history,
+		cfg:              cfg,
+		today:            todayIndex,
+		selected:         todayIndex,
+		dates:            week,
+		selectedHabit:    0,
+		selectedTask:     0,
+		mode:             cfg.DefaultView,
+		calendarDay:      today,
+		help:             help.New(),
+		nameInput:        newTextInput("", 80),
+		scheduleInput:    newTextInput("daily", 40),
+		goalInput:        newTextInput("none", 40),
+		descriptionInput: newTextInput("", 200),
+		noteInput:        newNoteInput(),
+		taskNameInput:    newTextInput("", 80),
+		taskDescInput:    newTextInput("", 200),
+		taskDueInput:     newTextInput("none", 20),
+		spinner:          spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+	// Read before the first frame, so the screen starts out filled in.
+	lists := readLists(m.store)
+	if lists.err == nil {
+		m.setLists(lists)
 	}
+	if err := errors.Join(historyErr, lists.err); err != nil {
+		m.fail("Loading", err)
+	}
+	m.loading = len(m.loadCmds())
+	return m
 }
 
 func (m modelState) Init() tea.Cmd {
-	return nil
+	cmds := append(m.loadCmds(), m.spinner.Tick)
+	if m.status != "" {
+		cmds = append(cmds, clearStatusAfter(m.statusID, errorTimeout))
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m modelState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(clearStatusMsg); ok {
+		if msg.id == m.statusID {
+			m.status = ""
+		}
+		return m, nil
+	}
+	if cmd, ok := m.receive(msg); ok {
+		return m, cmd
+	}
+
 	if m.editingNote {
 		if km, ok := msg.(tea.KeyMsg); ok {
 			switch {
 			case key.Matches(km, keys.Enter):
 				habit := m.habits[m.selectedHabit]
-				if habit.Notes == nil {
-					habit.Notes = make(map[string]string)
-				}
-				if habit.Type == "general" {
-					habit.Notes["general"] = m.editedNote
-				} else {
-					day := m.dates[m.selected].Weekday().String()
-					habit.Notes[day] = m.editedNote
+				text := strings.TrimSpace(m.noteInput.Value())
+				if err := m.store.SetNote(habit.ID, m.noteDate.Format("2006-01-02"), text); err != nil {
+					return m, m.fail("Saving the note", err)
 				}
-				model.UpdateHabit(habit.ID, habit)
-				m.habits, _ = model.GetHabits()
 				m.editingNote = false
+				m.noteInput.Blur()
+				if text == "" {
+					return m, tea.Batch(m.notify("Note removed"), m.refresh())
+				}
+				return m, tea.Batch(m.notify("Note saved"), m.refresh())
 			case key.Matches(km, keys.Escape):
 				m.editingNote = false
-			case key.Matches(km, keys.Backspace):
-				if len(m.editedNote) > 0 {
-					m.editedNote = m.editedNote[:len(m.editedNote)-1]
-				}
-			default:
-				if len(km.String()) == 1 {
-					m.editedNote += km.String()
-				}
+				m.noteInput.Blur()
+				return m, nil
 			}
 		}
-		return m, nil
+		var cmd tea.Cmd
+		m.noteInput, cmd = m.noteInput.Update(msg)
+		return m, cmd
 	}
 
+	var cmd tea.Cmd
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.help.Width = msg.Width
 	case tea.KeyMsg:
 		if m.mode == "adding_habit" || m.mode == "editing_habit" {
 			switch {
 			case key.Matches(msg, keys.Enter):
-				if m.editingField == "name" {
-					m.editingField = "description"
+				if strings.TrimSpace(m.nameInput.Value()) == "" {
+					m.formError = "The name can't be empty."
+					return m, m.focusField("name")
+				}
+				m.formError = ""
+				switch m.editingField {
+				case "name":
+					if m.newHabitKind == model.KindQuit {
+						// relapses are tracked daily and have no goal
+						return m, m.focusField("description")
+					}
+					return m, m.focusField("schedule")
+				case "schedule":
+					if _, err := model.ParseSchedule(m.scheduleInput.Value(), m.store.Clock().Today()); err != nil {
+						m.formError = err.Error()
+						return m, nil
+					}
+					return m, m.focusField("goal")
+				case "goal":
+					if _, err := model.ParseGoal(m.goalInput.Value()); err != nil {
+						m.formError = err.Error()
+						return m, nil
+					}
+					return m, m.focusField("description")
+				}
+				schedule, err := model.ParseSchedule(m.scheduleInput.Value(), m.store.Clock().Today())
+				if err != nil {
+					m.formError = err.Error()
+					return m, m.focusField("schedule")
+				}
+				goal, err := model.ParseGoal(m.goalInput.Value())
+				if err != nil {
+					m.formError = err.Error()
+					return m, m.focusField("goal")
+				}
+				name := strings.TrimSpace(m.nameInput.Value())
+				description := strings.TrimSpace(m.descriptionInput.Value())
+				confirmation := fmt.Sprintf("Added %q", name)
+				if m.mode == "adding_habit" {
+					habitID := strconv.FormatInt(time.Now().UnixNano(), 10)
+					err = m.store.AddHabit(model.Habit{
+						ID:          habitID,
+						Name:        name,
+						Description: description,
+						Type:        m.newHabitType,
+						Notes:       make(map[string]string),
+						Schedule:    schedule,
+						Goal:        goal,
+						Kind:        m.newHabitKind,
+					})
 				} else {
-					if m.mode == "adding_habit" {
-						habitID := strconv.FormatInt(time.Now().UnixNano(), 10)
-						model.AddHabit(habitID, m.newHabitName, m.newHabitDescription, m.newHabitType, make(map[string]string))
-					} else {
-						habit := m.habits[m.selectedHabit]
-						habit.Name = m.newHabitName
-						habit.Description = m.newHabitDescription
-						model.UpdateHabit(habit.ID, habit)
+					habit := m.habits[m.selectedHabit]
+					habit.Name = name
+					habit.Description = description
+					// re-parsing would move an interval's anchor to today
+					if schedule.String() != habit.Schedule.String() {
+						habit.Schedule = schedule
 					}
-					m.habits, _ = model.GetHabits()
-					m.mode = "habits"
-					m.newHabitName = ""
-					m.newHabitDescription = ""
+					habit.Goal = goal
+					err = m.store.UpdateHabit(habit.ID, habit)
+					confirmation = fmt.Sprintf("Saved %q", name)
 				}
+				if err != nil {
+					// keep the popup open so nothing typed is lost
+					return m, m.fail("Saving the habit", err)
+				}
+				m = m.resetHabitForm()
+				return m, tea.Batch(m.notify(confirmation), m.reload())
+			case key.Matches(msg, keys.Escape):
+				m = m.resetHabitForm()
+				return m, nil
+			}
+			field := m.habitField()
+			var cmd tea.Cmd
+			*field, cmd = field.Update(msg)
+			return m, cmd
+		}
+
+		if m.mode == "entering_value" {
+			var cmd tea.Cmd
+			switch {
+			case key.Matches(msg, keys.Enter):
+				entry, err := strconv.ParseFloat(m.valueInput, 64)
+				if err == nil {
+					err = model.ValidateValue(entry)
+				}
+				if err != nil {
+					m.formError = fmt.Sprintf("%q isn't a number.", m.valueInput)
+					return m, nil
+				}
+				habit := m.habits[m.selectedHabit]
+				dateStr := m.dates[m.selected].Format("2006-01-02")
+				current, err := dayValue(m.store, habit.ID, dateStr)
+				if err == nil {
+					err = m.store.SetHabitValue(habit.ID, dateStr, habit.Goal.Combine(current, entry))
+				}
+				cmd = tea.Batch(m.result(err, "Saving the value", ""), m.refresh())
+				m.mode = "habits"
+				m.valueInput = ""
+				m.formError = ""
 			case key.Matches(msg, keys.Escape):
 				m.mode = "habits"
-				m.newHabitName = ""
-				m.newHabitDescription = ""
+				m.valueInput = ""
+				m.formError = ""
 			case key.Matches(msg, keys.Backspace):
-				if m.editingField == "name" {
-					if len(m.newHabitName) > 0 {
-						m.newHabitName = m.newHabitName[:len(m.newHabitName)-1]
-					}
-				} else {
-					if len(m.newHabitDescription) > 0 {
-						m.newHabitDescription = m.newHabitDescription[:len(m.newHabitDescription)-1]
-					}
+				if len(m.valueInput) > 0 {
+					m.valueInput = m.valueInput[:len(m.valueInput)-1]
 				}
 			default:
-				if msg.Type == tea.KeyRunes {
-					if m.editingField == "name" {
-						m.newHabitName += msg.String()
-					} else {
-						m.newHabitDescription += msg.String()
-					}
+				if r := msg.String(); len(r) == 1 && strings.Contains("0123456789.", r) {
+					m.valueInput += r
 				}
 			}
-			return m, nil
+			return m, cmd
 		}
 
-		if m.mode == "adding_task" {
-			// ... (existing task adding logic)
+		if m.mode == "adding_task" || m.mode == "editing_task" {
+			return m.updateTaskForm(msg)
+		}
+
+		if m.mode == "deleting_task" {
+			return m.confirmTaskDelete(msg)
+		}
+
+		if m.mode == "deleting_habit" {
+			var cmd tea.Cmd
+			switch {
+			case key.Matches(msg, keys.Enter):
+				habit := m.archivedHabits[m.selectedArchived]
+				m.mode = "archived"
+				if err := m.store.DeleteHabit(habit.ID); err != nil {
+					return m, m.fail("Deleting the habit", err)
+				}
+				cmd = tea.Batch(m.notify(fmt.Sprintf("Deleted %q", habit.Name)), m.reload())
+			case key.Matches(msg, keys.Escape):
+				m.mode = "archived"
+			}
+			return m, cmd
+		}
+
+		if m.showHelp {
+			switch {
+			case key.Matches(msg, keys.Help), key.Matches(msg, keys.Escape):
+				m.showHelp = false
+			case key.Matches(msg, keys.Quit):
+				return m, tea.Quit
+			}
+			return m, nil
 		}
 
 		switch {
+		case key.Matches(msg, keys.Help):
+			m.showHelp = true
 		case key.Matches(msg, keys.Left):
-			if m.mode == "week" && m.selected > 0 {
-				m.selected--
+			if m.mode == "week" || m.mode == "habits" {
+				cmd = m.moveDay(-1)
 			} else if m.mode == "calendar" {
-				m.calendarMonth = m.calendarMonth.AddDate(0, -1, 0)
+				cmd = m.moveCalendar(m.calendarDay.AddDate(0, 0, -1))
 			}
 		case key.Matches(msg, keys.Right):
-			if m.mode == "week" && m.selected < len(m.dates)-1 {
-				m.selected++
+			if m.mode == "week" || m.mode == "habits" {
+				cmd = m.moveDay(1)
+			} else if m.mode == "calendar" {
+				cmd = m.moveCalendar(m.calendarDay.AddDate(0, 0, 1))
+			}
+		case key.Matches(msg, keys.PrevWeek), key.Matches(msg, keys.NextWeek):
+			if m.mode == "week" || m.mode == "habits" {
+				days := 7
+				if key.Matches(msg, keys.PrevWeek) {
+					days = -7
+				}
+				cmd = m.showWeek(m.dates[0].AddDate(0, 0, days))
 			} else if m.mode == "calendar" {
-				m.calendarMonth = m.calendarMonth.AddDate(0, 1, 0)
+				months := 1
+				if key.Matches(msg, keys.PrevWeek) {
+					months = -1
+				}
+				cmd = m.moveCalendar(addMonths(m.calendarDay, months))
+			}
+		case key.Matches(msg, keys.Today):
+			if m.mode == "week" || m.mode == "habits" {
+				cmd = m.showWeek(m.store.Clock().StartOfWeek(m.store.Clock().Today()))
+				m.selected = m.today
+			} else if m.mode == "calendar" {
+				cmd = m.moveCalendar(m.store.Clock().Today())
 			}
 		case key.Matches(msg, keys.Up):
-			if m.mode == "habits" && m.selectedHabit > 0 {
+			if m.mode == "choosing_habit_type" {
+				m.newHabitKind = otherKind(m.newHabitKind)
+			} else if m.mode == "calendar" {
+				cmd = m.moveCalendar(m.calendarDay.AddDate(0, 0, -7))
+			} else if m.mode == "habits" && m.selectedHabit > 0 {
 				m.selectedHabit--
 			} else if m.mode == "tasks" && m.selectedTask > 0 {
 				m.selectedTask--
@@ -228,7 +515,11 @@ func (m modelState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.selectedArchived--
 			}
 		case key.Matches(msg, keys.Down):
-			if m.mode == "habits" && m.selectedHabit < len(m.habits)-1 {
+			if m.mode == "choosing_habit_type" {
+				m.newHabitKind = otherKind(m.newHabitKind)
+			} else if m.mode == "calendar" {
+				cmd = m.moveCalendar(m.calendarDay.AddDate(0, 0, 7))
+			} else if m.mode == "habits" && m.selectedHabit < len(m.habits)-1 {
 				m.selectedHabit++
 			} else if m.mode == "tasks" && m.selectedTask < len(m.tasks)-1 {
 				m.selectedTask++
@@ -247,75 +538,135 @@ func (m modelState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if currentModeIndex != -1 {
 				m.mode = modes[(currentModeIndex+1)%len(modes)]
 			}
+			if m.mode == "stats" {
+				cmd = m.refresh()
+			}
 		case key.Matches(msg, keys.Enter):
 			if m.mode == "choosing_habit_type" {
 				m.mode = "adding_habit"
-				m.editingField = "name"
+				return m, m.focusField("name")
+			} else if m.mode == "week" {
+				m.mode = "habits"
+			} else if m.mode == "calendar" {
+				return m, m.editNote(m.calendarDay)
 			}
-		case key.Matches(msg, keys.N):
+		case key.Matches(msg, keys.Note):
 			if m.mode == "habits" && len(m.habits) > 0 {
-				m.showNotes = !m.showNotes
+				return m, m.editNote(m.dates[m.selected])
+			} else if m.mode == "calendar" {
+				return m, m.editNote(m.calendarDay)
 			}
-		case key.Matches(msg, keys.E):
+		case key.Matches(msg, keys.Edit):
 			if m.mode == "habits" && len(m.habits) > 0 {
 				m.mode = "editing_habit"
 				habit := m.habits[m.selectedHabit]
-				m.newHabitName = habit.Name
-				m.newHabitDescription = habit.Description
-				m.editingField = "name"
+				m.nameInput.SetValue(habit.Name)
+				m.scheduleInput.SetValue(habit.Schedule.String())
+				m.goalInput.SetValue(habit.Goal.String())
+				m.newHabitKind = habit.Kind
+				m.descriptionInput.SetValue(habit.Description)
+				return m, m.focusField("name")
+			} else if m.mode == "tasks" && len(m.tasks) > 0 {
+				m.mode = "editing_task"
+				task := m.tasks[m.selectedTask]
+				m.taskNameInput.SetValue(task.Name)
+				m.taskDescInput.SetValue(task.Description)
+				m.taskDueInput.SetValue(task.DueDate)
+				return m, m.focusTaskField("name")
+			}
+		case key.Matches(msg, keys.Increment), key.Matches(msg, keys.Decrement):
+			if m.mode == "habits" && len(m.habits) > 0 && m.habits[m.selectedHabit].Goal != nil {
+				habit := m.habits[m.selectedHabit]
+				dateStr := m.dates[m.selected].Format("2006-01-02")
+				step := 1.0
+				if key.Matches(msg, keys.Decrement) {
+					step = -1
+				}
+				current, err := dayValue(m.store, habit.ID, dateStr)
+				if err == nil {
+					err = m.store.SetHabitValue(habit.ID, dateStr, current+step)
+				}
+				cmd = tea.Batch(m.result(err, "Saving the value", ""), m.refresh())
 			}
 		case key.Matches(msg, keys.Space):
-			if m.mode == "habits" && len(m.habits) > 0 {
+			if m.mode == "habits" && len(m.habits) > 0 && m.habits[m.selectedHabit].Goal != nil {
+				m.mode = "entering_value"
+				m.valueInput = ""
+			} else if m.mode == "habits" && len(m.habits) > 0 {
 				dateStr := m.dates[m.selected].Format("2006-01-02")
-				model.ToggleHabitCompletion(m.habits[m.selectedHabit].ID, dateStr)
+				cmd = tea.Batch(m.result(m.store.ToggleHabitCompletion(m.habits[m.selectedHabit].ID, dateStr), "Saving the day", ""), m.refresh())
+			} else if m.mode == "calendar" && len(m.habits) > 0 {
+				cmd = tea.Batch(m.result(m.toggleDay(m.habits[m.selectedHabit], m.calendarDay), "Saving the day", ""), m.refresh())
 			} else if m.mode == "tasks" && len(m.tasks) > 0 {
-				model.ToggleTask(m.tasks[m.selectedTask].ID)
-				m.tasks, _ = model.GetTasks()
+				id := m.tasks[m.selectedTask].ID
+				if err := m.store.ToggleTask(id); err != nil {
+					return m, m.fail("Saving the task", err)
+				}
+				cmd = m.reload()
 			}
-		case key.Matches(msg, keys.A):
+		case key.Matches(msg, keys.Add):
 			if m.mode == "habits" {
 				m.mode = "choosing_habit_type"
 				m.newHabitType = "general"
+				m.newHabitKind = model.KindBuild
+				if m.cfg.DefaultHabitType == "quit" {
+					m.newHabitKind = model.KindQuit
+				}
 			} else if m.mode == "tasks" {
 				m.mode = "adding_task"
-				m.newTaskName = ""
+				return m, m.focusTaskField("name")
 			}
-		case key.Matches(msg, keys.D):
+		case key.Matches(msg, keys.Archive):
 			if m.mode == "habits" && len(m.habits) > 0 {
-				model.ArchiveHabit(m.habits[m.selectedHabit].ID)
-				m.habits, _ = model.GetHabits()
-				m.archivedHabits, _ = model.GetArchivedHabits()
-				if m.selectedHabit >= len(m.habits) && len(m.habits) > 0 {
-					m.selectedHabit = len(m.habits) - 1
+				habit := m.habits[m.selectedHabit]
+				if err := m.store.ArchiveHabit(habit.ID); err != nil {
+					return m, m.fail("Archiving the habit", err)
 				}
+				cmd = tea.Batch(m.notify(fmt.Sprintf("Archived %q", habit.Name)), m.reload())
 			}
-		case key.Matches(msg, keys.C):
+		case key.Matches(msg, keys.Delete):
+			if m.mode == "tasks" && len(m.tasks) > 0 {
+				m.mode = "deleting_task"
+			} else if m.mode == "archived" && len(m.archivedHabits) > 0 {
+				m.deleteCount = -1
+				m.mode = "deleting_habit"
+				cmd = m.refresh()
+			}
+		case key.Matches(msg, keys.Calendar):
 			if m.mode == "habits" && len(m.habits) > 0 {
 				m.mode = "calendar"
+				m.calendarDay = m.dates[m.selected]
+				cmd = m.refresh()
 			}
-		case key.Matches(msg, keys.U):
-			if m.mode == "archived" && len(m.archivedHabits) > 0 {
-				model.UnarchiveHabit(m.archivedHabits[m.selectedArchived].ID)
-				m.habits, _ = model.GetHabits()
-				m.archivedHabits, _ = model.GetArchivedHabits()
-				if m.selectedArchived >= len(m.archivedHabits) && len(m.archivedHabits) > 0 {
-					m.selectedArchived = len(m.archivedHabits) - 1
+		case key.Matches(msg, keys.Unarchive) && m.mode == "archived":
+			if len(m.archivedHabits) > 0 {
+				habit := m.archivedHabits[m.selectedArchived]
+				if err := m.store.UnarchiveHabit(habit.ID); err != nil {
+					return m, m.fail("Unarchiving the habit", err)
 				}
+				cmd = tea.Batch(m.notify(fmt.Sprintf("Unarchived %q", habit.Name)), m.reload())
 			}
-		case key.Matches(msg, keys.V):
+		case key.Matches(msg, keys.Undo):
+			cmd = m.walkHistory(true)
+		case key.Matches(msg, keys.Redo):
+			cmd = m.walkHistory(false)
+		case key.Matches(msg, keys.Archived):
 			m.mode = "archived"
 		case key.Matches(msg, keys.Escape):
-			if m.mode == "calendar" || m.mode == "archived" {
+			if m.mode == "calendar" {
+				// come back to the week holding the day the cursor was on
+				cmd = m.showWeek(m.store.Clock().StartOfWeek(m.calendarDay))
+				m.selected = int(m.calendarDay.Sub(m.dates[0]).Hours() / 24)
+			}
+			if m.mode == "calendar" || m.mode == "archived" || m.mode == "choosing_habit_type" {
 				m.mode = "habits"
-			} else if m.showNotes {
-				m.showNotes = false
 			}
 		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
 		}
 	}
 
-	return m, nil
+	return m, cmd
 }
 
 func (m modelState) View() string {
@@ -323,29 +674,42 @@ func (m modelState) View() string {
 
 	// Week View
 	if m.mode == "week" {
-		var dayCards []string
-		for range m.dates {
-			// ... (existing week view logic)
-		}
-		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, dayCards...))
+		s.WriteString(m.renderWeek() + "\n")
 	}
 
 	// Main Content
 	var contentBuilder strings.Builder
 	switch m.mode {
-	case "habits", "adding_habit", "editing_habit":
-		contentBuilder.WriteString("Habits for " + m.dates[m.selected].Format("Mon Jan 02") + "\n\n")
+	case "week", "habits", "adding_habit", "editing_habit", "entering_value":
+		contentBuilder.WriteString("Habits for " + m.dates[m.selected].Format(m.cfg.Dates.Day) + "\n\n")
 		if len(m.habits) == 0 {
-			contentBuilder.WriteString("No habits yet. Press 'a' to add one.")
+			contentBuilder.WriteString(fmt.Sprintf("No habits yet. Press %s to add one.", keys.Add.Help().Key))
 		} else {
 			dateStr := m.dates[m.selected].Format("2006-01-02")
 			for i, h := range m.habits {
-				completed, _ := model.IsHabitCompleted(h.ID, dateStr)
+				entry := m.week.entry(h.ID, m.dates[m.selected])
+				completed := entry.completed
+				progress := ""
+				if h.Goal != nil {
+					value := m.week.goalValue(h, m.dates[m.selected])
+					completed = value >= h.Goal.Target
+					progress = fmt.Sprintf("  %s/%s %s %s", model.FormatValue(value), model.FormatValue(h.Goal.Target), h.Goal.Unit, progressBar(h.Goal.Progress(value), 10))
+				}
 				var habitLine string
-				if completed {
-					habitLine = "✓ " + h.Name
+				if h.IsQuit() {
+					// A quit habit's completions are relapses.
+					if completed {
+						habitLine = "✗ " + h.Name + " (relapsed)"
+					} else {
+						habitLine = "✓ " + h.Name + " (clean)"
+					}
+					completed = !completed
+				} else if completed {
+					habitLine = "✓ " + h.Name + progress
+				} else if !h.Schedule.IsDue(dateStr) {
+					habitLine = "· " + h.Name + progress + " (not due)"
 				} else {
-					habitLine = "○ " + h.Name
+					habitLine = "○ " + h.Name + progress
 				}
 				style := incompleteHabitStyle
 				if m.mode == "habits" && i == m.selectedHabit {
@@ -354,30 +718,88 @@ func (m modelState) View() string {
 					style = completedHabitStyle
 				}
 				contentBuilder.WriteString(style.Render(habitLine) + "\n")
-				if i == m.selectedHabit {
+				if i == m.selectedHabit && m.mode != "week" {
 					contentBuilder.WriteString("  " + h.Description + "\n")
+					if entry.note != "" && !m.editingNote {
+						contentBuilder.WriteString("  Note: " + indent(entry.note, "        ") + "\n")
+					}
 				}
 			}
 		}
-	case "tasks", "adding_task":
-		// ... (existing task view logic)
+		if m.mode == "entering_value" {
+			habit := m.habits[m.selectedHabit]
+			contentBuilder.WriteString(fmt.Sprintf("\nAdd %s (%s): %s█", habit.Goal.Unit, habit.Goal.Aggregation, m.valueInput))
+			if m.formError != "" {
+				contentBuilder.WriteString("\n" + m.formError)
+			}
+		}
+	case "tasks", "adding_task", "editing_task", "deleting_task":
+		contentBuilder.WriteString(m.renderTasks())
 	case "stats":
-		contentBuilder.WriteString("Habit Statistics\n\n")
+		contentBuilder.WriteString("Habit Statistics" + m.spinnerView() + "\n\n")
 		if len(m.habits) == 0 {
 			contentBuilder.WriteString("No habits to show statistics for.")
 		} else {
 			for _, h := range m.habits {
-				currentStreak, _ := model.GetHabitStreak(h.ID)
-				longestStreak, _ := model.GetHabitLongestStreak(h.ID)
-				statsLine := fmt.Sprintf("%s\n  Current: %d days | Best: %d days", h.Name, currentStreak, longestStreak)
+				line, ok := m.stats[h.ID]
+				if !ok {
+					contentBuilder.WriteString(h.Name + "\n  Calculating…\n\n")
+					continue
+				}
+				stats := line.stats
+				if line.err != nil {
+					contentBuilder.WriteString(fmt.Sprintf("%s\n  Statistics unavailable: %v\n\n", h.Name, line.err))
+					continue
+				}
+				if h.IsQuit() {
+					statsLine := fmt.Sprintf("%s (quit)\n  Clean: %s | Longest clean: %s | Relapses: %d (%.1f per 30 days)",
+						h.Name, plural(stats.Current, "day"), plural(stats.Longest, "day"), stats.Total, stats.RelapseRate)
+					if stats.LastRelapse != "" {
+						statsLine += "\n  Last relapse: " + stats.LastRelapse
+					}
+					contentBuilder.WriteString(statsLine + "\n\n")
+					continue
+				}
+				statsLine := fmt.Sprintf("%s (%s)\n  Current: %s | Best: %s | Total: %d",
+					h.Name, h.Schedule, plural(stats.Current, stats.Unit), plural(stats.Longest, stats.Unit), stats.Total)
+				if stats.Longest > 0 {
+					statsLine += fmt.Sprintf("\n  Best run: %s → %s", stats.LongestStart, stats.LongestEnd)
+				}
+				if h.Goal != nil {
+					value := line.today
+					statsLine += fmt.Sprintf("\n  This %s: %s/%s %s %s", h.Goal.Per, model.FormatValue(value), model.FormatValue(h.Goal.Target), h.Goal.Unit, progressBar(h.Goal.Progress(value), 20))
+				}
 				contentBuilder.WriteString(statsLine + "\n\n")
 			}
 		}
+	case "choosing_habit_type":
+		contentBuilder.WriteString("What kind of habit?\n\n")
+		for _, kind := range []string{model.KindBuild, model.KindQuit} {
+			label := "Build a habit: mark the days you do it"
+			if kind == model.KindQuit {
+				label = "Quit a habit: mark the days you slip, count the days clean"
+			}
+			style := incompleteHabitStyle
+			if kind == m.newHabitKind {
+				style = selectedHabitStyle
+			}
+			contentBuilder.WriteString(style.Render(label) + "\n")
+		}
+		contentBuilder.WriteString(fmt.Sprintf("\n%s/%s to choose, %s to continue, %s to cancel",
+			keys.Up.Help().Key, keys.Down.Help().Key, keys.Enter.Help().Key, keys.Escape.Help().Key))
 	case "calendar":
+		if len(m.habits) == 0 {
+			break
+		}
 		habit := m.habits[m.selectedHabit]
-		contentBuilder.WriteString(fmt.Sprintf("Calendar for: %s (%s)\n", habit.Name, m.calendarMonth.Format("January 2006")))
-		contentBuilder.WriteString(renderCalendar(m.calendarMonth, habit.ID))
-	case "archived":
+		cal := m.calendar
+		if !cal.covers(habit.ID, m.calendarDay) {
+			cal = calendarData{} // still loading
+		}
+		contentBuilder.WriteString(fmt.Sprintf("Calendar for: %s (%s)%s\n", habit.Name, m.calendarDay.Format(m.cfg.Dates.Month), m.spinnerView()))
+		contentBuilder.WriteString(renderCalendar(cal, m.calendarDay, habit, m.store.Clock()))
+		contentBuilder.WriteString(renderMonthNotes(cal.notes))
+	case "archived", "deleting_habit":
 		contentBuilder.WriteString("Archived Habits\n\n")
 		if len(m.archivedHabits) == 0 {
 			contentBuilder.WriteString("No archived habits.")
@@ -390,12 +812,22 @@ func (m modelState) View() string {
 				contentBuilder.WriteString(style.Render(h.Name) + "\n")
 			}
 		}
+		if m.mode == "deleting_habit" {
+			habit := m.archivedHabits[m.selectedArchived]
+			count := "completions"
+			if m.deleteCount >= 0 {
+				count = plural(m.deleteCount, "completion")
+			}
+			contentBuilder.WriteString(fmt.Sprintf("\nDelete %q and its %s?%s\nhabit restore brings it back for %d days, then habit purge removes it for good.\n%s to delete, %s to keep it",
+				habit.Name, count, m.spinnerView(), int(model.DeleteGracePeriod.Hours()/24), keys.Enter.Help().Key, keys.Escape.Help().Key))
+		}
 	}
 
-	s.WriteString(habitSectionStyle.Render(contentBuilder.String()))
+	if m.editingNote {
+		contentBuilder.WriteString(fmt.Sprintf("\nNote for %s:\n%s", m.noteDate.Format(m.cfg.Dates.Day), m.noteInput.View()))
+	}
 
-	// Controls
-	// ... (add controls for new modes)
+	s.WriteString(habitSectionStyle.Render(contentBuilder.String()))
 
 	// Popups
 	if m.mode == "adding_habit" || m.mode == "editing_habit" {
@@ -405,56 +837,180 @@ func (m modelState) View() string {
 			title = "Edit Habit"
 		}
 		popupBuilder.WriteString(title + "\n")
-		name_field := m.newHabitName
-		desc_field := m.newHabitDescription
-		if m.editingField == "name" {
-			name_field += "█"
-		} else {
-			desc_field += "█"
+		popupBuilder.WriteString("Name: " + m.nameInput.View() + "\n")
+		popupBuilder.WriteString("Schedule: " + m.scheduleInput.View() + "\n")
+		popupBuilder.WriteString("  daily, mon,wed,fri, 3/week, 2/month or every 3 days\n")
+		popupBuilder.WriteString("Goal: " + m.goalInput.View() + "\n")
+		popupBuilder.WriteString("  optional, e.g. 8 glasses/day, 20 km/week, 100 pushups/day max\n")
+		popupBuilder.WriteString("Description: " + m.descriptionInput.View() + "\n")
+		if m.formError != "" {
+			popupBuilder.WriteString("\n" + m.formError + "\n")
 		}
-		popupBuilder.WriteString("Name: " + name_field + "\n")
-		popupBuilder.WriteString("Description: " + desc_field + "\n")
-		s.WriteString(lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1).Render(popupBuilder.String()))
+		s.WriteString("\n" + lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1).Render(popupBuilder.String()))
 	}
 
+	if m.mode == "adding_task" || m.mode == "editing_task" {
+		s.WriteString("\n" + m.renderTaskForm())
+	}
+
+	s.WriteString(m.renderStatus())
+
+	// Controls
+	s.WriteString("\n" + m.renderHelp())
+
 	return s.String()
 }
 
-func renderCalendar(month time.Time, habitID string) string {
-	var cal strings.Builder
-	startOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
-	endOfMonth := startOfMonth.AddDate(0, 1, -1)
-	startDay := int(startOfMonth.Weekday())
-
-	cal.WriteString(" Su Mo Tu We Th Fr Sa\n")
-	cal.WriteString(strings.Repeat("   ", startDay))
-
-	for day := 1; day <= endOfMonth.Day(); day++ {
-		date := time.Date(month.Year(), month.Month(), day, 0, 0, 0, 0, time.UTC)
-		dateStr := date.Format("2006-01-02")
-		completed, _ := model.IsHabitCompleted(habitID, dateStr)
-		dayStr := " "
-		if completed {
-			dayStr = "✓"
-		}
-		cal.WriteString(fmt.Sprintf(" %s ", dayStr))
-		if date.Weekday() == time.Saturday {
-			cal.WriteString("\n")
-		}
+func otherKind(kind string) string {
+	if kind == model.KindQuit {
+		return model.KindBuild
+	}
+	return model.KindQuit
+}
+
+func newTextInput(placeholder string, limit int) textinput.Model {
+	input := textinput.New()
+	input.Prompt = ""
+	input.Placeholder = placeholder
+	input.CharLimit = limit
+	input.Width = 40 // also needed for the placeholder to show in full
+	return input
+}
+
+// newNoteInput is the day note editor. Enter saves, so new lines are
+// typed with ctrl+j or alt+enter.
+func newNoteInput() textarea.Model {
+	input := textarea.New()
+	input.Placeholder = "What happened today?"
+	input.ShowLineNumbers = false
+	input.CharLimit = 2000
+	input.SetHeight(4)
+	input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("ctrl+j", "alt+enter"), key.WithHelp("ctrl+j", "new line"))
+	return input
+}
+
+// habitField returns the add/edit popup field that has focus.
+func (m *modelState) habitField() *textinput.Model {
+	switch m.editingField {
+	case "name":
+		return &m.nameInput
+	case "schedule":
+		return &m.scheduleInput
+	case "goal":
+		return &m.goalInput
+	default:
+		return &m.descriptionInput
+	}
+}
+
+// focusField moves the add/edit popup's cursor to field.
+func (m *modelState) focusField(field string) tea.Cmd {
+	m.habitField().Blur()
+	m.editingField = field
+	return m.habitField().Focus()
+}
+
+// walkHistory undoes the latest change, or redoes the latest undone one,
+// and reloads whatever it could have touched.
+func (m *modelState) walkHistory(undo bool) tea.Cmd {
+	walk, action, done, nothing := m.history.Redo, "Redoing", "Redone", model.ErrNothingToRedo
+	if undo {
+		walk, action, done, nothing = m.history.Undo, "Undoing", "Undone", model.ErrNothingToUndo
+	}
+	op, err := walk()
+	if errors.Is(err, nothing) {
+		return m.notify("Nothing to " + strings.ToLower(action[:4]))
+	}
+	if err != nil {
+		return m.fail(action, err)
 	}
-	cal.WriteString("\n")
-	return cal.String()
+	return tea.Batch(m.notify(done+": "+op.Summary), m.reload())
 }
 
-func StartApp() {
-	err := model.InitDB("tracker.db")
+func (m modelState) resetHabitForm() modelState {
+	m.mode = "habits"
+	for _, input := range []*textinput.Model{&m.nameInput, &m.scheduleInput, &m.goalInput, &m.descriptionInput} {
+		input.Reset()
+		input.Blur()
+	}
+	m.formError = ""
+	return m
+}
+
+// indent lines up the continuation lines of multi-line text under prefix.
+func indent(text, prefix string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// dayValue is the value recorded for a quantitative habit on date. It
+// reads the store rather than the week's snapshot because callers write
+// what they work out from it straight away: a snapshot still waiting on
+// the refresh after the previous key press would undo that press.
+func dayValue(store model.Store, habitID, date string) (float64, error) {
+	completions, err := store.CompletionsBetween(habitID, date, date)
+	if err != nil || len(completions) == 0 {
+		return 0, err
+	}
+	return completions[0].Value, nil
+}
+
+// goalValue is what counts towards h's goal on date: the day's value, or
+// the week's aggregate for weekly goals.
+func goalValue(store model.Store, h model.Habit, date time.Time) (float64, error) {
+	if h.Goal.Per != "week" {
+		return dayValue(store, h.ID, date.Format("2006-01-02"))
+	}
+	start := store.Clock().StartOfWeek(date)
+	completions, err := store.CompletionsBetween(h.ID, start.Format("2006-01-02"), start.AddDate(0, 0, 6).Format("2006-01-02"))
 	if err != nil {
-		fmt.Println("Failed to open DB:", err)
-		return
+		return 0, err
+	}
+	return h.Goal.Aggregate(completions), nil
+}
+
+func progressBar(fraction float64, width int) string {
+	filled := int(fraction*float64(width) + 0.5)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, unit)
 	}
-	defer model.CloseDB()
+	return fmt.Sprintf("%d %ss", n, unit)
+}
 
-	p := tea.NewProgram(initialModel())
+func renderMonthNotes(notes []model.DayNote) string {
+	if len(notes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\nNotes\n")
+	for _, n := range notes {
+		date, _ := time.Parse("2006-01-02", n.Date)
+		b.WriteString(fmt.Sprintf("  %s  %s\n", date.Format("Jan 02"), indent(n.Text, "          ")))
+	}
+	return b.String()
+}
+
+// StartApp runs the TUI against store. The caller owns the store and is
+// responsible for closing it.
+func StartApp(store model.Store, cfg config.Config) {
+	applyColors(cfg.Colors)
+	keys = newKeyMap(cfg.KeyMap())
+	m := initialModel(store, cfg)
+	if path, err := cfg.LogPath(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening log file:", err)
+	} else if path != "" {
+		f, err := openLog(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening log file:", err)
+		} else {
+			defer f.Close()
+			m.errorLog = log.New(f, "", log.LstdFlags)
+		}
+	}
+	p := tea.NewProgram(m)
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error running program:", err)
 	}
@@ -1,42 +1,65 @@
 package tui
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
+	"habit-tracker/config"
 	"habit-tracker/model"
-	"os"
+	"log"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestInitialModel(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
+func newTestStore(t *testing.T) model.Store {
+	store := model.NewMemoryStore(model.Clock{})
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// settle delivers what the current loads read, as the program would once
+// their commands finished: the lists first, then what the refresh they
+// start reads.
+func settle(m modelState) modelState {
+	if m.reloading {
+		next, _ := m.Update(loadLists(m.store, m.listsID)())
+		m = next.(modelState)
+	}
+	for _, cmd := range m.loadCmds() {
+		next, _ := m.Update(cmd())
+		m = next.(modelState)
+	}
+	return m
+}
+
+func dayNote(store model.Store, habitID, date string) (string, error) {
+	notes, err := store.NotesBetween(habitID, date, date)
+	if err != nil || len(notes) == 0 {
+		return "", err
 	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	return notes[0].Text, nil
+}
+
+func TestInitialModel(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
 
-	m := initialModel()
+	m := initialModel(store, config.Default())
 	if len(m.dates) != 7 {
 		t.Fatalf("expected 7 days, got %d", len(m.dates))
 	}
-	if m.mode != "calendar" {
-		t.Fatalf("expected mode calendar, got %s", m.mode)
+	if m.mode != "week" {
+		t.Fatalf("expected mode week, got %s", m.mode)
 	}
 }
 
 func TestTabCyclesModes(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
-	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	t.Parallel()
+	store := newTestStore(t)
 
-	m := initialModel()
+	m := initialModel(store, config.Default())
 	km := tea.KeyMsg{Type: tea.KeyTab}
 	next, _ := m.Update(km)
 	m2, ok := next.(modelState)
@@ -57,17 +80,13 @@ func TestTabCyclesModes(t *testing.T) {
 }
 
 func TestAddingTaskInput(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
-	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	t.Parallel()
+	store := newTestStore(t)
 
-	m := initialModel()
-	m.mode = "adding_task"
+	m := initialModel(store, config.Default())
+	m.mode = "tasks"
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = next.(modelState)
 
 	letters := []rune{'d', 'n', 'e', 'q', 'x'}
 	for _, r := range letters {
@@ -77,22 +96,16 @@ func TestAddingTaskInput(t *testing.T) {
 	}
 
 	expected := "dneqx"
-	if m.newTaskName != expected {
-		t.Fatalf("expected %s, got %s", expected, m.newTaskName)
+	if m.taskNameInput.Value() != expected {
+		t.Fatalf("expected %s, got %s", expected, m.taskNameInput.Value())
 	}
 }
 
 func TestArrowKeysMoveDaysInAllModes(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
-	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	t.Parallel()
+	store := newTestStore(t)
 
-	m := initialModel()
+	m := initialModel(store, config.Default())
 	m.mode = "habits"
 	m.selected = 3
 
@@ -112,22 +125,17 @@ func TestArrowKeysMoveDaysInAllModes(t *testing.T) {
 }
 
 func TestAddingHabit(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
-	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	t.Parallel()
+	store := newTestStore(t)
 
-	m := initialModel()
-	m.mode = "adding"
-	m.newHabitName = "new habit"
+	m := initialModel(store, config.Default())
+	m.mode = "adding_habit"
+	m.editingField = "description"
+	m.nameInput.SetValue("new habit")
 
 	enter := tea.KeyMsg{Type: tea.KeyEnter}
 	next, _ := m.Update(enter)
-	m2 := next.(modelState)
+	m2 := settle(next.(modelState))
 
 	if m2.mode != "habits" {
 		t.Fatalf("expected mode 'habits', got '%s'", m2.mode)
@@ -141,35 +149,34 @@ func TestAddingHabit(t *testing.T) {
 }
 
 func TestNoteEditing(t *testing.T) {
-	dbPath := "test.db"
-	if err := model.InitDB(dbPath); err != nil {
-		t.Fatalf("failed to init db: %v", err)
-	}
-	defer func() {
-		model.CloseDB()
-		os.Remove(dbPath)
-	}()
+	t.Parallel()
+	store := newTestStore(t)
 
-	model.AddHabit("1", "test habit", "general", nil)
-	m := initialModel()
+	store.AddHabit(model.Habit{ID: "1", Name: "test habit", Type: "general"})
+	m := initialModel(store, config.Default())
 	m.mode = "habits"
 	m.selectedHabit = 0
-	m.showNotes = true
-	m.editingNote = true
-	m.editedNote = "initial note"
+	date := m.dates[m.selected].Format("2006-01-02")
+	store.SetNote("1", date, "initial note")
 
-	// Simulate typing
-	km := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}}
-	next, _ := m.Update(km)
-	m = next.(modelState)
-	km = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}}
-	next, _ = m.Update(km)
-	m = next.(modelState)
-	km = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}}
-	next, _ = m.Update(km)
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if m := next.(modelState); m.editingNote {
+		t.Fatalf("expected no editor before the week is loaded, got note %q", m.noteInput.Value())
+	}
+	m = settle(m)
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
 	m = next.(modelState)
-	if m.editedNote != "initial noteabc" {
-		t.Fatalf("expected note 'initial noteabc', got '%s'", m.editedNote)
+	if !m.editingNote || m.noteInput.Value() != "initial note" {
+		t.Fatalf("expected n to edit the day's note, got editing=%v note=%q", m.editingNote, m.noteInput.Value())
+	}
+
+	// Simulate typing
+	for _, r := range "abc" {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = next.(modelState)
+	}
+	if m.noteInput.Value() != "initial noteabc" {
+		t.Fatalf("expected note 'initial noteabc', got '%s'", m.noteInput.Value())
 	}
 
 	// Simulate saving
@@ -180,8 +187,770 @@ func TestNoteEditing(t *testing.T) {
 	if m.editingNote != false {
 		t.Fatalf("expected editingNote to be false")
 	}
-	habits, _ := model.GetHabits()
-	if habits[0].Notes["general"] != "initial noteabc" {
-		t.Fatalf("expected note to be saved")
+	if note, _ := dayNote(store, "1", date); note != "initial noteabc" {
+		t.Fatalf("expected note to be saved for %s, got %q", date, note)
+	}
+	if note, _ := dayNote(store, "1", m.dates[(m.selected+1)%7].Format("2006-01-02")); note != "" {
+		t.Fatalf("expected the note to belong to one day only")
+	}
+	m = settle(m)
+	if !strings.Contains(m.View(), "Note: initial noteabc") {
+		t.Fatalf("expected the note in the habits view, got:\n%s", m.View())
+	}
+}
+
+func TestAddingHabitRejectsBadSchedule(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	m := initialModel(store, config.Default())
+	m.mode = "adding_habit"
+	m.editingField = "schedule"
+	m.nameInput.SetValue("gym")
+	m.scheduleInput.SetValue("9/week")
+
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	next, _ := m.Update(enter)
+	m = next.(modelState)
+	if m.editingField != "schedule" || m.formError == "" {
+		t.Fatalf("expected to stay on schedule with an error, got field %q error %q", m.editingField, m.formError)
+	}
+
+	m.scheduleInput.SetValue("mon,wed,fri")
+	for _, field := range []string{"goal", "description", "habits"} {
+		next, _ = m.Update(enter)
+		m = next.(modelState)
+		if m.editingField != field && m.mode != field {
+			t.Fatalf("expected %s next, got field %q mode %q", field, m.editingField, m.mode)
+		}
+	}
+	m = settle(m)
+	if len(m.habits) != 1 || m.habits[0].Schedule.String() != "mon,wed,fri" {
+		t.Fatalf("expected habit with mon,wed,fri schedule, got %+v", m.habits)
+	}
+}
+
+func TestQuantitativeHabitValueEntry(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	water, _ := model.ParseGoal("8 glasses/day")
+	store.AddHabit(model.Habit{ID: "1", Name: "water", Goal: water})
+	m := initialModel(store, config.Default())
+	m.mode = "habits"
+	date := m.dates[m.selected].Format("2006-01-02")
+
+	plus := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}}
+	next, _ := m.Update(plus)
+	m = next.(modelState)
+	next, _ = m.Update(plus)
+	m = next.(modelState)
+	if v, _ := dayValue(store, "1", date); v != 2 {
+		t.Fatalf("expected 2 after two increments, got %v", v)
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m = next.(modelState)
+	if m.mode != "entering_value" {
+		t.Fatalf("expected space to open value entry, got mode %q", m.mode)
+	}
+	for _, r := range "6.." {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = next.(modelState)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(modelState)
+	if m.mode != "entering_value" || !strings.Contains(m.View(), `"6.." isn't a number`) {
+		t.Fatalf("expected an unparseable value to stay open with an error, got mode %q:\n%s", m.mode, m.View())
+	}
+	for range 2 {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		m = next.(modelState)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(modelState)
+	if v, _ := dayValue(store, "1", date); v != 8 {
+		t.Fatalf("expected sum of 8 after entering 6, got %v", v)
+	}
+	m = settle(m)
+	if !strings.Contains(m.View(), "8/8 glasses") {
+		t.Fatalf("expected progress in view, got:\n%s", m.View())
+	}
+}
+
+func TestAddingQuitHabit(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	m := initialModel(store, config.Default())
+	m.mode = "habits"
+	keys := []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune{'a'}},
+		{Type: tea.KeyDown},
+		{Type: tea.KeyEnter},
+	}
+	for _, k := range keys {
+		next, _ := m.Update(k)
+		m = next.(modelState)
+	}
+	if m.mode != "adding_habit" || m.newHabitKind != model.KindQuit {
+		t.Fatalf("expected to be adding a quit habit, got mode %q kind %q", m.mode, m.newHabitKind)
+	}
+	m.nameInput.SetValue("smoking")
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(modelState)
+	if m.editingField != "description" {
+		t.Fatalf("expected quit habits to skip schedule and goal, got field %q", m.editingField)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = settle(next.(modelState))
+	if len(m.habits) != 1 || !m.habits[0].IsQuit() {
+		t.Fatalf("expected one quit habit, got %+v", m.habits)
+	}
+	if !strings.Contains(m.View(), "✓ smoking (clean)") {
+		t.Fatalf("expected clean day in view, got:\n%s", m.View())
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m = settle(next.(modelState))
+	if !strings.Contains(m.View(), "✗ smoking (relapsed)") {
+		t.Fatalf("expected relapse in view, got:\n%s", m.View())
+	}
+}
+
+func TestConfigDefaultViewAndDateFormat(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+
+	cfg := config.Default()
+	cfg.DefaultView = "habits"
+	cfg.Dates.Day = "2006/01/02"
+	m := initialModel(store, cfg)
+	if m.mode != "habits" {
+		t.Fatalf("expected to open on the habits tab, got %q", m.mode)
+	}
+	if want := "Habits for " + m.dates[m.selected].Format("2006/01/02"); !strings.Contains(m.View(), want) {
+		t.Fatalf("expected %q in view, got:\n%s", want, m.View())
+	}
+	if !m.dates[m.today].Equal(store.Clock().Today()) {
+		t.Fatalf("expected today at index %d, got %v", m.today, m.dates[m.today])
+	}
+}
+
+func TestVimKeyPreset(t *testing.T) {
+	cfg := config.Default()
+	cfg.KeyPreset = "vim"
+	keys = newKeyMap(cfg.KeyMap())
+	t.Cleanup(func() { keys = newKeyMap(config.Default().KeyMap()) })
+
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+	store.AddHabit(model.Habit{ID: "2", Name: "run"})
+	m := initialModel(store, cfg)
+	m.mode = "habits"
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	if m = next.(modelState); m.selectedHabit != 1 {
+		t.Fatalf("expected j to move down, got habit %d", m.selectedHabit)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m = next.(modelState); m.selectedHabit != 1 {
+		t.Fatalf("expected arrows to keep working, got habit %d", m.selectedHabit)
+	}
+	if label := keys.Down.Help().Key; label != "j/↓" {
+		t.Fatalf("expected help to show the bound keys, got %q", label)
+	}
+}
+
+func TestHelpFollowsMode(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	m := initialModel(store, config.Default())
+
+	m.mode = "habits"
+	if view := m.View(); !strings.Contains(view, "archive") || !strings.Contains(view, "? all keys") {
+		t.Fatalf("expected habit keys in the help line:\n%s", view)
+	}
+	m.mode = "tasks"
+	if view := m.View(); strings.Contains(view, "archive") {
+		t.Fatalf("expected no archive key on the tasks tab:\n%s", view)
+	}
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	m = next.(modelState)
+	if view := m.View(); !m.showHelp || !strings.Contains(view, "view archived") || !strings.Contains(view, "quit") {
+		t.Fatalf("expected the full key list:\n%s", view)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if m = next.(modelState); m.mode != "tasks" {
+		t.Fatalf("expected keys other than closing to be ignored, got mode %s", m.mode)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m = next.(modelState); m.showHelp {
+		t.Fatal("expected esc to close the overlay")
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = next.(modelState)
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if m = next.(modelState); m.showHelp || m.taskNameInput.Value() != "?" {
+		t.Fatalf("expected ? to be typed into a field, got %q", m.taskNameInput.Value())
+	}
+}
+
+func TestTextInputs(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "journal"})
+	m := settle(initialModel(store, config.Default()))
+	m.mode = "habits"
+
+	typeText := func(text string) {
+		for _, r := range text {
+			next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			m = next.(modelState)
+		}
+	}
+	press := func(k tea.KeyType) {
+		next, _ := m.Update(tea.KeyMsg{Type: k})
+		m = next.(modelState)
+	}
+
+	// Notes keep multi-byte characters whole and span lines.
+	typeText("n")
+	typeText("café")
+	press(tea.KeyBackspace)
+	typeText("é 🎉")
+	press(tea.KeyCtrlJ)
+	typeText("日記")
+	press(tea.KeyEnter)
+	date := m.dates[m.selected].Format("2006-01-02")
+	if note, _ := dayNote(store, "1", date); note != "café 🎉\n日記" {
+		t.Fatalf("unexpected note %q", note)
+	}
+
+	// An empty name can't be saved.
+	m.mode = "adding_habit"
+	m.focusField("name")
+	typeText("  ")
+	press(tea.KeyEnter)
+	if m.editingField != "name" || m.formError == "" {
+		t.Fatalf("expected an error for an empty name, got field %q error %q", m.editingField, m.formError)
+	}
+	m.focusField("description")
+	press(tea.KeyEnter)
+	if m.mode != "adding_habit" || len(m.habits) != 1 {
+		t.Fatalf("expected no habit to be added, got mode %q habits %+v", m.mode, m.habits)
+	}
+}
+
+func TestTaskTab(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddTask("1", "call mum", "", "")
+	m := initialModel(store, config.Default())
+	m.mode = "tasks"
+
+	send := func(msgs ...tea.KeyMsg) {
+		for _, msg := range msgs {
+			next, _ := m.Update(msg)
+			m = settle(next.(modelState))
+		}
+	}
+	typeText := func(text string) {
+		for _, r := range text {
+			send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	typeText("file taxes")
+	send(enter)
+	typeText("form 1040")
+	send(enter)
+	typeText("2000-13-01")
+	send(enter)
+	if m.mode != "adding_task" || m.formError == "" {
+		t.Fatalf("expected a bad due date to be rejected, got mode %q", m.mode)
+	}
+	m.taskDueInput.SetValue("2000-01-15")
+	send(enter)
+	if m.mode != "tasks" || len(m.tasks) != 2 || m.tasks[0].Name != "file taxes" || m.tasks[0].DueDate != "2000-01-15" {
+		t.Fatalf("expected the dated task first, got mode %q tasks %+v", m.mode, m.tasks)
+	}
+	if view := m.View(); !strings.Contains(view, "Pending") || !strings.Contains(view, "overdue since") || !strings.Contains(view, "form 1040") {
+		t.Fatalf("expected an overdue task with its description:\n%s", view)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if m.tasks[1].Name != "file taxes" || !m.tasks[1].Completed || m.selectedTask != 1 {
+		t.Fatalf("expected the completed task to move down with the selection, got %+v (selected %d)", m.tasks, m.selectedTask)
+	}
+	if view := m.View(); !strings.Contains(view, "Completed") || strings.Contains(view, "overdue") {
+		t.Fatalf("expected a completed section and nothing overdue:\n%s", view)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	send(tea.KeyMsg{Type: tea.KeyCtrlU})
+	typeText("pay taxes")
+	send(enter, enter, enter)
+	if m.tasks[1].Name != "pay taxes" || m.tasks[1].Description != "form 1040" || !m.tasks[1].Completed {
+		t.Fatalf("expected the task to be renamed, got %+v", m.tasks[1])
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}, tea.KeyMsg{Type: tea.KeyEsc})
+	if len(m.tasks) != 2 {
+		t.Fatal("expected esc to keep the task")
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if !strings.Contains(m.View(), `Delete "pay taxes"?`) {
+		t.Fatalf("expected a confirmation:\n%s", m.View())
+	}
+	send(enter)
+	if tasks, _ := store.GetTasks(); len(tasks) != 1 || m.selectedTask != 0 {
+		t.Fatalf("expected the task to be deleted, got %+v (selected %d)", tasks, m.selectedTask)
+	}
+}
+
+func TestWeekStrip(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read", CreatedAt: "2000-01-01"})
+	store.AddHabit(model.Habit{ID: "2", Name: "run", CreatedAt: "2000-01-01"})
+	today := store.Clock().Today()
+	store.ToggleHabitCompletion("1", today.Format("2006-01-02"))
+	m := settle(initialModel(store, config.Default()))
+
+	if view := m.View(); !strings.Contains(view, "1/2 done") || !strings.Contains(view, today.Format("Jan 2")) {
+		t.Fatalf("expected today's card with its progress:\n%s", view)
+	}
+
+	send := func(msg tea.KeyMsg) {
+		next, _ := m.Update(msg)
+		m = settle(next.(modelState))
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{']'}})
+	if !m.dates[0].Equal(store.Clock().StartOfWeek(today).AddDate(0, 0, 7)) || m.today != -1 {
+		t.Fatalf("expected next week, got %v (today %d)", m.dates[0], m.today)
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if !m.dates[m.selected].Equal(today) || m.selected != m.today {
+		t.Fatalf("expected to jump back to today, got %v", m.dates[m.selected])
+	}
+
+	m.selected = 0
+	send(tea.KeyMsg{Type: tea.KeyLeft})
+	if m.selected != 6 || !m.dates[6].Equal(store.Clock().StartOfWeek(today).AddDate(0, 0, -1)) {
+		t.Fatalf("expected left from the first day to open the previous week, got %v", m.dates[m.selected])
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != "habits" {
+		t.Fatalf("expected enter to open the day's habits, got %q", m.mode)
+	}
+}
+
+func TestWeekStripQuotas(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	start := store.Clock().StartOfWeek(day("2024-03-14"))
+	date := func(i int) string { return start.AddDate(0, 0, i).Format("2006-01-02") }
+	store.AddHabit(model.Habit{ID: "1", Name: "gym", CreatedAt: "2000-01-01", Schedule: model.Schedule{Kind: model.ScheduleWeekly, Times: 2}})
+	store.AddHabit(model.Habit{ID: "2", Name: "swim", CreatedAt: "2000-01-01", Schedule: model.Schedule{Kind: model.ScheduleWeekly, Times: 3}})
+	store.AddHabit(model.Habit{ID: "3", Name: "call", CreatedAt: "2000-01-01", Schedule: model.Schedule{Kind: model.ScheduleMonthly, Times: 2}})
+	for _, c := range [][2]string{{"1", date(0)}, {"1", date(1)}, {"2", date(0)}, {"3", "2024-03-01"}, {"3", "2024-03-02"}} {
+		store.ToggleHabitCompletion(c[0], c[1])
+	}
+	m := initialModel(store, config.Default())
+	m.showWeek(start)
+	m = settle(m)
+
+	if done, due := m.dayProgress(start); done != 2 || due != 2 {
+		t.Fatalf("expected the two habits done on the first day, got %d/%d", done, due)
+	}
+	if done, due := m.dayProgress(start.AddDate(0, 0, 3)); done != 0 || due != 1 {
+		t.Fatalf("expected only the unmet weekly quota due later in the week, got %d/%d", done, due)
+	}
+}
+
+func TestCalendarCursor(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+	m := initialModel(store, config.Default())
+	m.mode = "habits"
+
+	send := func(msgs ...tea.KeyMsg) {
+		for _, msg := range msgs {
+			next, _ := m.Update(msg)
+			m = settle(next.(modelState))
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	send(runes("c"))
+	if m.mode != "calendar" || !m.calendarDay.Equal(m.dates[m.selected]) {
+		t.Fatalf("expected the cursor on the selected day, got mode %q day %v", m.mode, m.calendarDay)
+	}
+
+	m.calendarDay = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	send(tea.KeyMsg{Type: tea.KeyLeft}, tea.KeyMsg{Type: tea.KeyUp})
+	if want := time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC); !m.calendarDay.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, m.calendarDay)
+	}
+	send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if done, _ := store.IsHabitCompleted("1", "2024-03-07"); !done {
+		t.Fatal("expected space to toggle the cursor's day")
+	}
+	m = settle(m)
+	if view := m.View(); !strings.Contains(view, " 7✓") || !strings.Contains(view, "15") {
+		t.Fatalf("expected day numbers with marks:\n%s", view)
+	}
+
+	send(runes("]"))
+	if m.calendarDay.Month() != time.April {
+		t.Fatalf("expected ] to move a month, got %v", m.calendarDay)
+	}
+	send(runes("["), tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.editingNote || !m.noteDate.Equal(time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected enter to open the cursor's note, got %v", m.noteDate)
+	}
+	send(runes("rainy"), tea.KeyMsg{Type: tea.KeyEnter})
+	if note, _ := dayNote(store, "1", "2024-03-07"); note != "rainy" {
+		t.Fatalf("expected the note on 2024-03-07, got %q", note)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != "habits" || m.dates[m.selected].Format("2006-01-02") != "2024-03-07" {
+		t.Fatalf("expected to come back to the cursor's day, got %v", m.dates[m.selected])
+	}
+}
+
+func TestDeletingArchivedHabit(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+	store.ToggleHabitCompletion("1", "2024-03-01")
+	store.ToggleHabitCompletion("1", "2024-03-02")
+	store.ArchiveHabit("1")
+	m := initialModel(store, config.Default())
+	m.mode = "archived"
+
+	send := func(msg tea.KeyMsg) {
+		next, _ := m.Update(msg)
+		m = settle(next.(modelState))
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if view := m.View(); m.mode != "deleting_habit" || !strings.Contains(view, `Delete "read" and its 2 completions?`) {
+		t.Fatalf("expected a confirmation with the completion count:\n%s", view)
+	}
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	if archived, _ := store.GetArchivedHabits(); m.mode != "archived" || len(archived) != 1 {
+		t.Fatalf("expected esc to keep the habit, got mode %q", m.mode)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	deleted, _ := store.GetDeletedHabits()
+	if len(m.archivedHabits) != 0 || len(deleted) != 1 || m.status != `Deleted "read"` {
+		t.Fatalf("expected the habit soft deleted, got archived %+v deleted %+v status %q", m.archivedHabits, deleted, m.status)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+	m := initialModel(store, config.Default())
+	m.mode = "habits"
+	date := m.dates[m.selected].Format("2006-01-02")
+
+	send := func(msg tea.KeyMsg) {
+		next, _ := m.Update(msg)
+		m = settle(next.(modelState))
+	}
+	send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if len(m.habits) != 0 {
+		t.Fatalf("expected the habit archived, got %+v", m.habits)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if len(m.habits) != 1 || m.status != `Undone: archived "read"` {
+		t.Fatalf("expected u to bring the habit back, got %+v status %q", m.habits, m.status)
+	}
+	send(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if done, _ := store.IsHabitCompleted("1", date); done {
+		t.Fatal("expected ctrl+z to undo the toggle")
+	}
+	send(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if m.status != "Nothing to undo" {
+		t.Fatalf("expected nothing left to undo, got %q", m.status)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if done, _ := store.IsHabitCompleted("1", date); !done || m.status != fmt.Sprintf(`Redone: toggled "read" on %s`, date) {
+		t.Fatalf("expected ctrl+r to redo the toggle, got status %q", m.status)
+	}
+	m = settle(m)
+	if !strings.Contains(m.View(), "✓ read") {
+		t.Fatalf("expected the redone toggle on screen:\n%s", m.View())
+	}
+
+	cli, _ := model.OpenHistory(store)
+	if op, err := cli.Undo(); err != nil || op.Completion == nil {
+		t.Fatalf("expected the command line to undo the TUI's toggle, got %q (%v)", op.Summary, err)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	m.mode = "archived"
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if len(m.habits) != 1 || len(m.archivedHabits) != 0 || m.status != `Unarchived "read"` {
+		t.Fatalf("expected u to unarchive in the archived tab, got status %q", m.status)
+	}
+	m.showHelp = true
+	if view := m.View(); !strings.Contains(view, "ctrl+z undo") || strings.Contains(view, "u/ctrl+z undo") {
+		t.Fatalf("expected the archived help to leave u to unarchive:\n%s", view)
+	}
+}
+
+func TestUndoClosesCalendarOfRemovedHabit(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+	m := initialModel(store, config.Default())
+	m.mode = "habits"
+	if err := m.store.AddHabit(model.Habit{ID: "1", Name: "read"}); err != nil {
+		t.Fatal(err)
+	}
+	m.reload()
+	m = settle(m)
+
+	send := func(msg tea.KeyMsg) {
+		next, _ := m.Update(msg)
+		m = settle(next.(modelState))
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	if m.mode != "calendar" {
+		t.Fatalf("expected the calendar, got mode %q", m.mode)
+	}
+	send(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if len(m.habits) != 0 || m.mode != "habits" {
+		t.Fatalf("expected undoing the add to leave the calendar, got mode %q with %+v", m.mode, m.habits)
+	}
+	m.View()
+
+	m.mode = "calendar" // e.g. another session removed it first
+	send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m.View()
+}
+
+func TestAddMonths(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ from, want string }{
+		{"2024-01-31", "2024-02-29"},
+		{"2024-03-15", "2024-04-15"},
+		{"2024-12-31", "2025-01-31"},
+	}
+	for _, tt := range tests {
+		from, _ := time.Parse("2006-01-02", tt.from)
+		if got := addMonths(from, 1).Format("2006-01-02"); got != tt.want {
+			t.Errorf("addMonths(%s, 1) = %s, want %s", tt.from, got, tt.want)
+		}
 	}
-}
\ No newline at end of file
+}
+
+// lockedStore fails every write the way a busy database would.
+type lockedStore struct{ model.Store }
+
+var errLocked = fmt.Errorf("write: %w", errors.New("database locked"))
+
+func (lockedStore) ArchiveHabit(string) error                  { return errLocked }
+func (lockedStore) ToggleHabitCompletion(string, string) error { return errLocked }
+
+// unreadableStore fails to read completions and notes.
+type unreadableStore struct {
+	model.Store
+	writes int
+}
+
+var errUnreadable = errors.New("read: database corrupt")
+
+func (*unreadableStore) CompletionsBetween(string, string, string) ([]model.HabitCompletion, error) {
+	return nil, errUnreadable
+}
+
+func (*unreadableStore) NotesBetween(string, string, string) ([]model.DayNote, error) {
+	return nil, errUnreadable
+}
+
+func (s *unreadableStore) SetHabitValue(habitID, date string, value float64) error {
+	s.writes++
+	return s.Store.SetHabitValue(habitID, date, value)
+}
+
+func TestReadErrorsStopWrites(t *testing.T) {
+	t.Parallel()
+	store := &unreadableStore{Store: newTestStore(t)}
+	water, _ := model.ParseGoal("8 glasses/day")
+	store.AddHabit(model.Habit{ID: "1", Name: "water", Goal: water})
+	m := settle(initialModel(store, config.Default()))
+	m.mode = "habits"
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+	m = next.(modelState)
+	if !strings.Contains(m.status, "Saving the value failed: read: database corrupt") || store.writes != 0 {
+		t.Fatalf("expected the read error and no write, got %q after %d writes", m.status, store.writes)
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = settle(next.(modelState))
+	if m.editingNote || !strings.Contains(m.status, "Loading the week failed: read: database corrupt") {
+		t.Fatalf("expected the note editor to stay closed, got %q", m.status)
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	t.Parallel()
+	var logged bytes.Buffer
+	store := newTestStore(t)
+	store.AddHabit(model.Habit{ID: "1", Name: "read"})
+	m := initialModel(lockedStore{store}, config.Default())
+	m.errorLog = log.New(&logged, "", 0)
+	m.mode = "habits"
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	m = next.(modelState)
+	if !strings.Contains(m.View(), "Archiving the habit failed: write: database locked") || !m.statusIsError || cmd == nil {
+		t.Fatalf("expected the error in the status line:\n%s", m.View())
+	}
+	if len(m.habits) != 1 {
+		t.Fatal("expected the habit to stay listed")
+	}
+	if !strings.Contains(logged.String(), "caused by *errors.errorString: database locked") {
+		t.Fatalf("expected the error chain in the log, got:\n%s", logged.String())
+	}
+
+	next, _ = m.Update(clearStatusMsg{id: m.statusID - 1})
+	if m = next.(modelState); m.status == "" {
+		t.Fatal("expected a stale timeout to leave the status alone")
+	}
+	next, _ = m.Update(clearStatusMsg{id: m.statusID})
+	if m = next.(modelState); m.status != "" {
+		t.Fatal("expected the status to clear after its timeout")
+	}
+
+	m.store = store
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if m = settle(next.(modelState)); m.status != `Archived "read"` || m.statusIsError || len(m.habits) != 0 {
+		t.Fatalf("expected a confirmation, got %q", m.status)
+	}
+}
+
+// readCountingStore counts the reads behind what the screen shows.
+type readCountingStore struct {
+	model.Store
+	reads int
+}
+
+func (s *readCountingStore) IsHabitCompleted(habitID, date string) (bool, error) {
+	s.reads++
+	return s.Store.IsHabitCompleted(habitID, date)
+}
+
+func (s *readCountingStore) CompletionsBetween(habitID, from, to string) ([]model.HabitCompletion, error) {
+	s.reads++
+	return s.Store.CompletionsBetween(habitID, from, to)
+}
+
+func (s *readCountingStore) NotesBetween(habitID, from, to string) ([]model.DayNote, error) {
+	s.reads++
+	return s.Store.NotesBetween(habitID, from, to)
+}
+
+func (s *readCountingStore) GetHabitStats(habitID string) (model.StreakStats, error) {
+	s.reads++
+	return s.Store.GetHabitStats(habitID)
+}
+
+func (s *readCountingStore) GetHabits() ([]model.Habit, error) {
+	s.reads++
+	return s.Store.GetHabits()
+}
+
+func (s *readCountingStore) GetArchivedHabits() ([]model.Habit, error) {
+	s.reads++
+	return s.Store.GetArchivedHabits()
+}
+
+func (s *readCountingStore) GetTasks() ([]model.Task, error) {
+	s.reads++
+	return s.Store.GetTasks()
+}
+
+func TestViewReadsOnlyLoadedData(t *testing.T) {
+	t.Parallel()
+	store := &readCountingStore{Store: newTestStore(t)}
+	store.AddHabit(model.Habit{ID: "1", Name: "read", CreatedAt: "2000-01-01"})
+	store.ToggleHabitCompletion("1", store.Clock().Today().Format("2006-01-02"))
+	m := initialModel(store, config.Default())
+	if m.loading == 0 || strings.Contains(m.View(), "1/1 done") {
+		t.Fatalf("expected the week to be loading, got %d loads:\n%s", m.loading, m.View())
+	}
+
+	m = settle(m)
+	store.reads = 0
+	if view := m.View(); !strings.Contains(view, "1/1 done") || store.reads != 0 {
+		t.Fatalf("expected the loaded week without store reads, got %d reads:\n%s", store.reads, view)
+	}
+
+	m.mode = "tasks"
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = next.(modelState)
+	if m.mode != "stats" || !strings.Contains(m.View(), "Calculating…") || m.loading == 0 {
+		t.Fatalf("expected stats to load after switching to them:\n%s", m.View())
+	}
+	stale := m.loadCmds()
+	m.refresh()
+	for _, cmd := range stale {
+		next, _ = m.Update(cmd())
+		m = next.(modelState)
+	}
+	if m.stats != nil {
+		t.Fatal("expected results of a superseded refresh to be dropped")
+	}
+
+	m = settle(m)
+	store.reads = 0
+	if view := m.View(); !strings.Contains(view, "Current: 1 day") || m.loading != 0 || store.reads != 0 {
+		t.Fatalf("expected loaded stats without store reads, got %d reads:\n%s", store.reads, view)
+	}
+
+	m.mode = "habits"
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if m = next.(modelState); store.reads != 0 || m.noteInput.Value() != "" || !m.editingNote {
+		t.Fatalf("expected the note from the loaded week, got %d reads", store.reads)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(modelState)
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}})
+	if m = next.(modelState); len(m.habits) != 1 || !m.reloading {
+		t.Fatalf("expected the habit list to be left to a command, got %+v", m.habits)
+	}
+	m = settle(m)
+	m.mode = "archived"
+	store.reads = 0
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = next.(modelState)
+	if store.reads != 0 || !strings.Contains(m.View(), `Delete "read" and its completions?`) {
+		t.Fatalf("expected the count to be left to a command, got %d reads:\n%s", store.reads, m.View())
+	}
+	if view := settle(m).View(); !strings.Contains(view, `Delete "read" and its 1 completion?`) {
+		t.Fatalf("expected the count once loaded:\n%s", view)
+	}
+}
@@ -0,0 +1,110 @@
+// File: tui/calendar.go
+package tui
+
+import (
+	"fmt"
+	"habit-tracker/model"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// addMonths moves day by n months, keeping to the last day of shorter
+// months rather than spilling into the next one.
+func addMonths(day time.Time, n int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day.Day(), last)-1)
+}
+
+// editNote opens the note editor on the selected habit's note for day.
+func (m *modelState) editNote(day time.Time) tea.Cmd {
+	if len(m.habits) == 0 {
+		return nil
+	}
+	note, ok := m.loadedNote(m.habits[m.selectedHabit].ID, day)
+	switch {
+	case !ok && m.busy():
+		return m.notify("Still loading, try again in a moment")
+	case !ok:
+		return m.refresh() // the load failed; try again, saying why if it fails again
+	}
+	m.editingNote = true
+	m.noteDate = day
+	m.noteInput.SetValue(note)
+	return m.noteInput.Focus()
+}
+
+// toggleDay flips habit's entry for day. For a habit with a goal that
+// means meeting the target or clearing the day's value.
+func (m modelState) toggleDay(habit model.Habit, day time.Time) error {
+	date := day.Format("2006-01-02")
+	if habit.Goal == nil {
+		return m.store.ToggleHabitCompletion(habit.ID, date)
+	}
+	value, err := dayValue(m.store, habit.ID, date)
+	if err != nil {
+		return err
+	}
+	if value > 0 {
+		return m.store.SetHabitValue(habit.ID, date, 0)
+	}
+	return m.store.SetHabitValue(habit.ID, date, habit.Goal.Target)
+}
+
+// moveCalendar puts the calendar's cursor on day, loading its month when
+// that's a different one.
+func (m *modelState) moveCalendar(day time.Time) tea.Cmd {
+	month := firstOfMonth(m.calendarDay)
+	m.calendarDay = day
+	if firstOfMonth(day).Equal(month) {
+		return nil
+	}
+	return m.refresh()
+}
+
+// renderCalendar draws cursor's month for habit from data, with the
+// cursor's day highlighted and weeks laid out by clock. Days carry ✓ when
+// done (✗ for a quit habit's relapses) and · when the habit isn't due.
+func renderCalendar(data calendarData, cursor time.Time, habit model.Habit, clock model.Clock) string {
+	var cal strings.Builder
+	startOfMonth := firstOfMonth(cursor)
+	endOfMonth := startOfMonth.AddDate(0, 1, -1)
+	startDay := int(startOfMonth.Sub(clock.StartOfWeek(startOfMonth)).Hours() / 24)
+	today := clock.Today()
+
+	for i := range 7 {
+		cal.WriteString("  " + ((clock.WeekStart + time.Weekday(i)) % 7).String()[:2])
+	}
+	cal.WriteString("\n")
+	cal.WriteString(strings.Repeat("    ", startDay))
+
+	for day := 1; day <= endOfMonth.Day(); day++ {
+		date := time.Date(cursor.Year(), cursor.Month(), day, 0, 0, 0, 0, time.UTC)
+		dateStr := date.Format("2006-01-02")
+		mark := " "
+		if data.completed[dateStr] && habit.IsQuit() {
+			mark = "✗"
+		} else if data.completed[dateStr] {
+			mark = "✓"
+		} else if !habit.IsQuit() && !habit.Schedule.IsDue(dateStr) {
+			mark = "·"
+		}
+		cell := fmt.Sprintf("%2d%s", day, mark)
+		switch {
+		case date.Equal(cursor):
+			cell = calendarCursorStyle.Render(cell)
+		case date.Equal(today):
+			cell = calendarTodayStyle.Render(cell)
+		case date.After(today):
+			cell = calendarFutureStyle.Render(cell)
+		}
+		cal.WriteString(" " + cell)
+		if date.AddDate(0, 0, 1).Weekday() == clock.WeekStart {
+			cal.WriteString("\n")
+		}
+	}
+	cal.WriteString("\n")
+	return cal.String()
+}
@@ -0,0 +1,404 @@
+// File: tui/data.go
+package tui
+
+import (
+	"cmp"
+	"errors"
+	"habit-tracker/model"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// View draws from the snapshots below and never reads the store. refresh
+// starts commands that read what the current mode shows; their results
+// come back as messages, so a slow store shows a spinner rather than
+// freezing the screen.
+
+// dayEntry is what a habit has recorded for one day.
+type dayEntry struct {
+	completed bool // for a quit habit, a relapse
+	value     float64
+	note      string
+}
+
+// weekData holds the shown week's entries by habit ID and date.
+type weekData struct {
+	start   time.Time
+	entries map[string]map[string]dayEntry
+	totals  map[string]float64 // weekly goals' aggregates by habit ID
+	// quotaMet says, by habit ID and first date, which of the quota
+	// periods overlapping the week are met; see model.Habit.QuotaPeriod.
+	quotaMet map[string]map[string]bool
+}
+
+func (w weekData) entry(habitID string, date time.Time) dayEntry {
+	return w.entries[habitID][date.Format("2006-01-02")]
+}
+
+// covers reports whether w was loaded for habitID's week holding day.
+func (w weekData) covers(habitID string, day time.Time) bool {
+	_, ok := w.entries[habitID]
+	return ok && !day.Before(w.start) && day.Before(w.start.AddDate(0, 0, 7))
+}
+
+// goalValue is what counts towards h's goal on date, which must be in the
+// week w holds.
+func (w weekData) goalValue(h model.Habit, date time.Time) float64 {
+	if h.Goal.Per == "week" {
+		return w.totals[h.ID]
+	}
+	return w.entry(h.ID, date).value
+}
+
+// habitStats is one habit's line on the stats tab.
+type habitStats struct {
+	stats model.StreakStats
+	today float64 // towards the goal today, for habits with one
+	err   error
+}
+
+// calendarData holds a habit's completions and notes for one month.
+type calendarData struct {
+	habitID   string
+	month     time.Time // its first day
+	completed map[string]bool
+	notes     []model.DayNote
+}
+
+// covers reports whether c was loaded for habitID's month holding day.
+func (c calendarData) covers(habitID string, day time.Time) bool {
+	return c.habitID == habitID && c.month.Equal(firstOfMonth(day))
+}
+
+func firstOfMonth(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Each load carries the loadID of the refresh that started it, or the
+// listsID of the reload, so results overtaken by a later one are dropped.
+type (
+	listsLoadedMsg struct {
+		id       int
+		habits   []model.Habit
+		archived []model.Habit
+		tasks    []model.Task
+		err      error
+	}
+	weekLoadedMsg struct {
+		id   int
+		week weekData
+		err  error
+	}
+	statsLoadedMsg struct {
+		id    int
+		stats map[string]habitStats
+	}
+	calendarLoadedMsg struct {
+		id       int
+		calendar calendarData
+		err      error
+	}
+	countLoadedMsg struct {
+		id    int
+		count int
+		err   error
+	}
+)
+
+// readLists reads the active and archived habits and the tasks.
+func readLists(store model.Store) listsLoadedMsg {
+	var msg listsLoadedMsg
+	var errs [3]error
+	msg.habits, errs[0] = store.GetHabits()
+	msg.archived, errs[1] = store.GetArchivedHabits()
+	msg.tasks, errs[2] = store.GetTasks()
+	msg.err = errors.Join(errs[:]...)
+	return msg
+}
+
+func loadLists(store model.Store, id int) tea.Cmd {
+	return func() tea.Msg {
+		msg := readLists(store)
+		msg.id = id
+		return msg
+	}
+}
+
+func loadWeek(store model.Store, id int, habits []model.Habit, start time.Time) tea.Cmd {
+	return func() tea.Msg {
+		from, to := start.Format("2006-01-02"), start.AddDate(0, 0, 6).Format("2006-01-02")
+		week := weekData{
+			start:    start,
+			entries:  make(map[string]map[string]dayEntry, len(habits)),
+			totals:   make(map[string]float64),
+			quotaMet: make(map[string]map[string]bool),
+		}
+		weekStart := store.Clock().WeekStart
+		var errs []error
+		for _, h := range habits {
+			completions, err := store.CompletionsBetween(h.ID, from, to)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			notes, err := store.NotesBetween(h.ID, from, to)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			entries := make(map[string]dayEntry, len(completions)+len(notes))
+			for _, c := range completions {
+				entries[c.Date] = dayEntry{completed: true, value: c.Value}
+			}
+			for _, n := range notes {
+				e := entries[n.Date]
+				e.note = n.Text
+				entries[n.Date] = e
+			}
+			week.entries[h.ID] = entries
+			if h.Goal != nil && h.Goal.Per == "week" {
+				week.totals[h.ID] = h.Goal.Aggregate(completions)
+			}
+			// A month can start or end inside the week.
+			for _, date := range []string{from, to} {
+				first, last, ok := h.QuotaPeriod(date, weekStart)
+				if !ok {
+					break
+				}
+				if _, ok := week.quotaMet[h.ID][first]; ok {
+					continue
+				}
+				inPeriod, err := store.CompletionsBetween(h.ID, first, last)
+				if err != nil {
+					errs = append(errs, err)
+					break
+				}
+				if week.quotaMet[h.ID] == nil {
+					week.quotaMet[h.ID] = make(map[string]bool)
+				}
+				week.quotaMet[h.ID][first] = h.PeriodMet(inPeriod)
+			}
+		}
+		return weekLoadedMsg{id, week, errors.Join(errs...)}
+	}
+}
+
+func loadStats(store model.Store, id int, habits []model.Habit) tea.Cmd {
+	return func() tea.Msg {
+		stats := make(map[string]habitStats, len(habits))
+		for _, h := range habits {
+			s, err := store.GetHabitStats(h.ID)
+			line := habitStats{stats: s, err: err}
+			if h.Goal != nil && err == nil {
+				line.today, line.err = goalValue(store, h, store.Clock().Today())
+			}
+			stats[h.ID] = line
+		}
+		return statsLoadedMsg{id, stats}
+	}
+}
+
+func loadCalendar(store model.Store, id int, habit model.Habit, day time.Time) tea.Cmd {
+	return func() tea.Msg {
+		month := firstOfMonth(day)
+		from, to := month.Format("2006-01-02"), month.AddDate(0, 1, -1).Format("2006-01-02")
+		cal := calendarData{habitID: habit.ID, month: month}
+		completions, err := store.CompletionsBetween(habit.ID, from, to)
+		if err != nil {
+			return calendarLoadedMsg{id: id, err: err}
+		}
+		cal.completed = make(map[string]bool, len(completions))
+		for _, c := range completions {
+			cal.completed[c.Date] = true
+		}
+		if cal.notes, err = store.NotesBetween(habit.ID, from, to); err != nil {
+			return calendarLoadedMsg{id: id, err: err}
+		}
+		return calendarLoadedMsg{id, cal, nil}
+	}
+}
+
+// loadCompletionCount counts every completion of habitID, for the
+// question before deleting it.
+func loadCompletionCount(store model.Store, id int, habitID string) tea.Cmd {
+	return func() tea.Msg {
+		completions, err := store.CompletionsBetween(habitID, "", "9999-12-31")
+		return countLoadedMsg{id, len(completions), err}
+	}
+}
+
+// loadCmds reads what the current mode shows: the week always, and the
+// stats, the calendar's month or the completions about to be deleted
+// when they're on screen.
+func (m modelState) loadCmds() []tea.Cmd {
+	cmds := []tea.Cmd{loadWeek(m.store, m.loadID, m.habits, m.dates[0])}
+	switch {
+	case m.mode == "stats":
+		cmds = append(cmds, loadStats(m.store, m.loadID, m.habits))
+	case m.mode == "calendar" && len(m.habits) > 0:
+		cmds = append(cmds, loadCalendar(m.store, m.loadID, m.habits[m.selectedHabit], m.calendarDay))
+	case m.mode == "deleting_habit":
+		cmds = append(cmds, loadCompletionCount(m.store, m.loadID, m.archivedHabits[m.selectedArchived].ID))
+	}
+	return cmds
+}
+
+// refresh rereads what's on screen. Call it after changing the store or
+// moving to data that isn't loaded.
+func (m *modelState) refresh() tea.Cmd {
+	m.loadID++
+	cmds := m.loadCmds()
+	m.loading = len(cmds)
+	return tea.Batch(append(cmds, m.spinner.Tick)...)
+}
+
+// reload rereads the habit and task lists, then refreshes what's on
+// screen: the week is read for the habits in them. Call it after adding,
+// removing or renaming a habit or task.
+func (m *modelState) reload() tea.Cmd {
+	m.listsID++
+	m.reloading = true
+	return tea.Batch(loadLists(m.store, m.listsID), m.spinner.Tick)
+}
+
+// setLists puts freshly read lists in place. The selected habit, archived
+// habit and task stay selected while they're still there, or the one
+// pendingTask names once it is; otherwise the selection keeps its place.
+// A calendar or a question about deleting something that went away
+// closes.
+func (m *modelState) setLists(msg listsLoadedMsg) {
+	habitID := selectedID(m.habits, m.selectedHabit, func(h model.Habit) string { return h.ID })
+	archivedID := selectedID(m.archivedHabits, m.selectedArchived, func(h model.Habit) string { return h.ID })
+	taskID := cmp.Or(m.pendingTask, selectedID(m.tasks, m.selectedTask, func(t model.Task) string { return t.ID }))
+	m.habits, m.archivedHabits, m.tasks = msg.habits, msg.archived, msg.tasks
+	m.pendingTask = ""
+	sortTasks(m.tasks)
+
+	var found bool
+	m.selectedHabit, found = reselect(m.habits, m.selectedHabit, func(h model.Habit) bool { return h.ID == habitID })
+	if !found && m.mode == "calendar" {
+		m.mode = "habits"
+	}
+	m.selectedArchived, found = reselect(m.archivedHabits, m.selectedArchived, func(h model.Habit) bool { return h.ID == archivedID })
+	if !found && m.mode == "deleting_habit" {
+		m.mode = "archived"
+	}
+	m.selectedTask, found = reselect(m.tasks, m.selectedTask, func(t model.Task) bool { return t.ID == taskID })
+	if !found && m.mode == "deleting_task" {
+		m.mode = "tasks"
+	}
+}
+
+func selectedID[T any](list []T, i int, id func(T) string) string {
+	if i < len(list) {
+		return id(list[i])
+	}
+	return ""
+}
+
+// reselect finds the item matching is in list, or keeps i in range if
+// there's none.
+func reselect[T any](list []T, i int, is func(T) bool) (int, bool) {
+	if j := slices.IndexFunc(list, is); j >= 0 {
+		return j, true
+	}
+	return max(min(i, len(list)-1), 0), false
+}
+
+// receive keeps a load's result, unless a later refresh has superseded
+// it, and keeps the spinner turning while loads are running. ok is false
+// for messages that aren't about loading.
+func (m *modelState) receive(msg tea.Msg) (cmd tea.Cmd, ok bool) {
+	switch msg := msg.(type) {
+	case listsLoadedMsg:
+		if msg.id != m.listsID {
+			break
+		}
+		m.reloading = false
+		if msg.err != nil {
+			return m.fail("Loading habits and tasks", msg.err), true
+		}
+		m.setLists(msg)
+		return m.refresh(), true
+	case weekLoadedMsg:
+		if msg.id != m.loadID {
+			break
+		}
+		m.loading--
+		if msg.err != nil {
+			return m.fail("Loading the week", msg.err), true
+		}
+		m.week = msg.week
+	case statsLoadedMsg:
+		if msg.id == m.loadID {
+			m.loading--
+			m.stats = msg.stats
+		}
+	case calendarLoadedMsg:
+		if msg.id != m.loadID {
+			break
+		}
+		m.loading--
+		if msg.err != nil {
+			return m.fail("Loading the calendar", msg.err), true
+		}
+		m.calendar = msg.calendar
+	case countLoadedMsg:
+		if msg.id != m.loadID {
+			break
+		}
+		m.loading--
+		if msg.err != nil {
+			if m.mode == "deleting_habit" {
+				m.mode = "archived"
+			}
+			return m.fail("Counting completions", msg.err), true
+		}
+		m.deleteCount = msg.count
+	case spinner.TickMsg:
+		if m.busy() {
+			m.spinner, cmd = m.spinner.Update(msg)
+		}
+	default:
+		return nil, false
+	}
+	return cmd, true
+}
+
+// busy reports whether any load is still running, and so whether the
+// snapshots may be behind the store.
+func (m modelState) busy() bool {
+	return m.loading > 0 || m.reloading
+}
+
+// loadedNote is habitID's note on day from whichever snapshot holds it.
+// ok is false while neither does or a load is running, since the note may
+// just have changed.
+func (m modelState) loadedNote(habitID string, day time.Time) (note string, ok bool) {
+	switch {
+	case m.busy():
+		return "", false
+	case m.calendar.covers(habitID, day):
+		date := day.Format("2006-01-02")
+		for _, n := range m.calendar.notes {
+			if n.Date == date {
+				return n.Text, true
+			}
+		}
+		return "", true
+	case m.week.covers(habitID, day):
+		return m.week.entry(habitID, day).note, true
+	}
+	return "", false
+}
+
+// spinnerView is the spinner while anything is loading.
+func (m modelState) spinnerView() string {
+	if !m.busy() {
+		return ""
+	}
+	return " " + m.spinner.View()
+}
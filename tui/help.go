@@ -0,0 +1,82 @@
+// File: tui/help.go
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpKeys returns the bindings that do something in the current mode: a
+// few for the help line and all of them, grouped, for the ? overlay.
+func (m modelState) helpKeys() (short []key.Binding, full [][]key.Binding) {
+	if m.editingNote {
+		newline := m.noteInput.KeyMap.InsertNewline
+		return []key.Binding{keys.Enter, newline, keys.Escape}, [][]key.Binding{{keys.Enter, newline, keys.Escape}}
+	}
+	switch m.mode {
+	case "week":
+		return []key.Binding{keys.Left, keys.Right, keys.PrevWeek, keys.NextWeek, keys.Today, keys.Enter, keys.Tab, keys.Help},
+			[][]key.Binding{{keys.Left, keys.Right, keys.PrevWeek, keys.NextWeek, keys.Today}, {keys.Enter, keys.Tab, keys.Archived}, {keys.Help, keys.Quit}}
+	case "habits":
+		short = []key.Binding{keys.Space, keys.Add, keys.Edit, keys.Note, keys.Archive, keys.Calendar, keys.Help}
+		full = [][]key.Binding{
+			{keys.Up, keys.Down, keys.Left, keys.Right, keys.PrevWeek, keys.NextWeek, keys.Today},
+			{keys.Space, keys.Increment, keys.Decrement, keys.Note},
+			{keys.Add, keys.Edit, keys.Archive, keys.Calendar},
+			{keys.Undo, keys.Redo},
+			{keys.Tab, keys.Archived, keys.Help, keys.Quit},
+		}
+		if len(m.habits) > 0 && m.habits[m.selectedHabit].Goal != nil {
+			short = append([]key.Binding{keys.Increment, keys.Decrement}, short...)
+		}
+		return short, full
+	case "tasks":
+		return []key.Binding{keys.Space, keys.Add, keys.Edit, keys.Delete, keys.Tab, keys.Help},
+			[][]key.Binding{{keys.Up, keys.Down}, {keys.Space, keys.Add, keys.Edit, keys.Delete}, {keys.Undo, keys.Redo}, {keys.Tab, keys.Archived, keys.Help, keys.Quit}}
+	case "stats":
+		return []key.Binding{keys.Tab, keys.Help, keys.Quit},
+			[][]key.Binding{{keys.Tab, keys.Archived}, {keys.Help, keys.Quit}}
+	case "archived":
+		return []key.Binding{keys.Up, keys.Down, keys.Unarchive, keys.Delete, keys.Escape, keys.Help},
+			[][]key.Binding{{keys.Up, keys.Down}, {keys.Unarchive, keys.Delete, keys.Escape}, {keys.ArchivedUndo, keys.Redo}, {keys.Tab, keys.Help, keys.Quit}}
+	case "calendar":
+		toggle, open := relabel(keys.Space, "toggle day"), relabel(keys.Enter, "open note")
+		prevMonth, nextMonth := relabel(keys.PrevWeek, "previous month"), relabel(keys.NextWeek, "next month")
+		return []key.Binding{toggle, open, prevMonth, nextMonth, keys.Escape, keys.Help},
+			[][]key.Binding{
+				{keys.Left, keys.Right, relabel(keys.Up, "week up"), relabel(keys.Down, "week down")},
+				{prevMonth, nextMonth, keys.Today},
+				{toggle, open, keys.Escape},
+				{keys.Undo, keys.Redo},
+				{keys.Help, keys.Quit},
+			}
+	case "choosing_habit_type", "deleting_task", "deleting_habit":
+		return []key.Binding{keys.Up, keys.Down, keys.Enter, keys.Escape},
+			[][]key.Binding{{keys.Up, keys.Down}, {keys.Enter, keys.Escape}}
+	default: // typing into a field
+		return []key.Binding{keys.Enter, keys.Escape},
+			[][]key.Binding{{keys.Enter, keys.Escape, keys.Backspace}}
+	}
+}
+
+// renderHelp is the help line, or the full list of keys while the overlay
+// is open.
+func (m modelState) renderHelp() string {
+	short, full := m.helpKeys()
+	if !m.showHelp {
+		return controlsStyle.Render(m.help.ShortHelpView(short))
+	}
+	closeKeys := keyLabel(keys.Help.Keys()) + "/" + keyLabel(keys.Escape.Keys())
+	return habitSectionStyle.Render(lipgloss.JoinVertical(lipgloss.Left,
+		"Keys",
+		"",
+		m.help.FullHelpView(full),
+		"",
+		controlsStyle.Render(closeKeys+" to close"),
+	))
+}
+
+// relabel is b with help text that fits the current mode.
+func relabel(b key.Binding, help string) key.Binding {
+	return key.NewBinding(key.WithKeys(b.Keys()...), key.WithHelp(b.Help().Key, help))
+}
@@ -0,0 +1,88 @@
+// File: tui/status.go
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	statusTimeout = 4 * time.Second
+	errorTimeout  = 10 * time.Second
+	// statusWidth is as much of an error as the status line shows; the log
+	// file gets all of it.
+	statusWidth = 100
+)
+
+// openLog opens the config's log_file for StartApp, which hands it to the
+// model as errorLog.
+func openLog(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
+
+// clearStatusMsg clears the status line unless a newer message replaced
+// the one it was scheduled for.
+type clearStatusMsg struct{ id int }
+
+func clearStatusAfter(id int, d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg { return clearStatusMsg{id} })
+}
+
+// notify shows text in the status line until it times out. Empty text
+// leaves the status line alone.
+func (m *modelState) notify(text string) tea.Cmd {
+	if text == "" {
+		return nil
+	}
+	m.statusID++
+	m.status = text
+	m.statusIsError = false
+	return clearStatusAfter(m.statusID, statusTimeout)
+}
+
+// fail reports that action failed with err, in the status line and in the
+// log file if there is one.
+func (m *modelState) fail(action string, err error) tea.Cmd {
+	if m.errorLog != nil {
+		m.errorLog.Printf("%s: %v", action, err)
+		for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+			m.errorLog.Printf("  caused by %T: %v", e, e)
+		}
+	}
+	text := fmt.Sprintf("%s failed: %v", action, err)
+	if r := []rune(text); len(r) > statusWidth {
+		text = string(r[:statusWidth-1]) + "…"
+	}
+	m.statusID++
+	m.status = strings.ReplaceAll(text, "\n", " ")
+	m.statusIsError = true
+	return clearStatusAfter(m.statusID, errorTimeout)
+}
+
+// result reports how action went: err if there was one, otherwise the
+// confirmation.
+func (m *modelState) result(err error, action, confirmation string) tea.Cmd {
+	if err != nil {
+		return m.fail(action, err)
+	}
+	return m.notify(confirmation)
+}
+
+func (m modelState) renderStatus() string {
+	if m.status == "" {
+		return ""
+	}
+	if m.statusIsError {
+		return "\n" + errorStatusStyle.Render(m.status)
+	}
+	return "\n" + statusStyle.Render(m.status)
+}
@@ -0,0 +1,232 @@
+// File: tui/tasks.go
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"habit-tracker/model"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sortTasks orders tasks as the tasks tab lists them: pending before
+// completed, then by due date with undated tasks last, then oldest first.
+func sortTasks(tasks []model.Task) {
+	slices.SortStableFunc(tasks, func(a, b model.Task) int {
+		if a.Completed != b.Completed {
+			if a.Completed {
+				return 1
+			}
+			return -1
+		}
+		if (a.DueDate == "") != (b.DueDate == "") {
+			if a.DueDate == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(strings.Compare(a.DueDate, b.DueDate), strings.Compare(a.ID, b.ID))
+	})
+}
+
+// parseDueDate accepts today, tomorrow or YYYY-MM-DD. Empty means no due date.
+func parseDueDate(s string, today time.Time) (string, error) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "":
+		return "", nil
+	case "today":
+		return today.Format("2006-01-02"), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1).Format("2006-01-02"), nil
+	}
+	if _, err := time.Parse("2006-01-02", s); err != nil {
+		return "", fmt.Errorf("due date %q: want today, tomorrow or YYYY-MM-DD", s)
+	}
+	return s, nil
+}
+
+// dueLabel describes when t is due, relative to today. Only pending tasks
+// are overdue.
+func (m modelState) dueLabel(t model.Task) (label string, overdue bool) {
+	if t.DueDate == "" {
+		return "", false
+	}
+	due, err := time.Parse("2006-01-02", t.DueDate)
+	if err != nil {
+		return "due " + t.DueDate, false
+	}
+	today := m.store.Clock().Today()
+	switch {
+	case t.Completed:
+		return "due " + due.Format(m.cfg.Dates.Day), false
+	case due.Equal(today):
+		return "due today", false
+	case due.Equal(today.AddDate(0, 0, 1)):
+		return "due tomorrow", false
+	case due.Before(today):
+		return "overdue since " + due.Format(m.cfg.Dates.Day), true
+	}
+	return "due " + due.Format(m.cfg.Dates.Day), false
+}
+
+func (m modelState) renderTasks() string {
+	var b strings.Builder
+	b.WriteString("Tasks\n")
+	if len(m.tasks) == 0 {
+		b.WriteString(fmt.Sprintf("\nNo tasks yet. Press %s to add one.", keys.Add.Help().Key))
+		return b.String()
+	}
+	for i, t := range m.tasks {
+		if i == 0 && !t.Completed {
+			b.WriteString("\nPending\n")
+		}
+		if t.Completed && (i == 0 || !m.tasks[i-1].Completed) {
+			b.WriteString("\nCompleted\n")
+		}
+
+		line := "○ " + t.Name
+		if t.Completed {
+			line = "✓ " + t.Name
+		}
+		due, overdue := m.dueLabel(t)
+		if due != "" {
+			line += "  " + due
+		}
+		style := incompleteHabitStyle
+		switch {
+		case i == m.selectedTask:
+			style = selectedHabitStyle
+		case t.Completed:
+			style = completedHabitStyle
+		case overdue:
+			style = overdueTaskStyle
+		}
+		b.WriteString(style.Render(line) + "\n")
+		if i == m.selectedTask && t.Description != "" {
+			b.WriteString("  " + t.Description + "\n")
+		}
+	}
+	if m.mode == "deleting_task" {
+		b.WriteString(fmt.Sprintf("\nDelete %q? %s to delete, %s to keep it",
+			m.tasks[m.selectedTask].Name, keys.Enter.Help().Key, keys.Escape.Help().Key))
+	}
+	return b.String()
+}
+
+func (m modelState) renderTaskForm() string {
+	var b strings.Builder
+	title := "Add Task"
+	if m.mode == "editing_task" {
+		title = "Edit Task"
+	}
+	b.WriteString(title + "\n")
+	b.WriteString("Name: " + m.taskNameInput.View() + "\n")
+	b.WriteString("Description: " + m.taskDescInput.View() + "\n")
+	b.WriteString("Due: " + m.taskDueInput.View() + "\n")
+	b.WriteString("  optional: today, tomorrow or YYYY-MM-DD\n")
+	if m.formError != "" {
+		b.WriteString("\n" + m.formError + "\n")
+	}
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1).Render(b.String())
+}
+
+// updateTaskForm handles keys while the add or edit task popup is open.
+// Enter moves through the fields and saves from the last one.
+func (m modelState) updateTaskForm(msg tea.KeyMsg) (modelState, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Enter):
+		if strings.TrimSpace(m.taskNameInput.Value()) == "" {
+			m.formError = "The name can't be empty."
+			return m, m.focusTaskField("name")
+		}
+		m.formError = ""
+		switch m.taskField {
+		case "name":
+			return m, m.focusTaskField("description")
+		case "description":
+			return m, m.focusTaskField("due")
+		}
+		due, err := parseDueDate(m.taskDueInput.Value(), m.store.Clock().Today())
+		if err != nil {
+			m.formError = err.Error()
+			return m, nil
+		}
+		name := strings.TrimSpace(m.taskNameInput.Value())
+		description := strings.TrimSpace(m.taskDescInput.Value())
+		var id string
+		confirmation := fmt.Sprintf("Added %q", name)
+		if m.mode == "adding_task" {
+			id = strconv.FormatInt(time.Now().UnixNano(), 10)
+			err = m.store.AddTask(id, name, description, due)
+		} else {
+			id = m.tasks[m.selectedTask].ID
+			err = m.store.UpdateTask(id, model.Task{Name: name, Description: description, DueDate: due})
+			confirmation = fmt.Sprintf("Saved %q", name)
+		}
+		if err != nil {
+			return m, m.fail("Saving the task", err)
+		}
+		m.pendingTask = id
+		m = m.resetTaskForm()
+		return m, tea.Batch(m.notify(confirmation), m.reload())
+	case key.Matches(msg, keys.Escape):
+		return m.resetTaskForm(), nil
+	}
+	field := m.taskInput()
+	var cmd tea.Cmd
+	*field, cmd = field.Update(msg)
+	return m, cmd
+}
+
+// confirmTaskDelete handles keys while deleting the selected task waits
+// for confirmation.
+func (m modelState) confirmTaskDelete(msg tea.KeyMsg) (modelState, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Enter):
+		task := m.tasks[m.selectedTask]
+		m.mode = "tasks"
+		if err := m.store.DeleteTask(task.ID); err != nil {
+			return m, m.fail("Deleting the task", err)
+		}
+		return m, tea.Batch(m.notify(fmt.Sprintf("Deleted %q", task.Name)), m.reload())
+	case key.Matches(msg, keys.Escape):
+		m.mode = "tasks"
+	}
+	return m, nil
+}
+
+// taskInput returns the task popup field that has focus.
+func (m *modelState) taskInput() *textinput.Model {
+	switch m.taskField {
+	case "name":
+		return &m.taskNameInput
+	case "description":
+		return &m.taskDescInput
+	default:
+		return &m.taskDueInput
+	}
+}
+
+// focusTaskField moves the task popup's cursor to field.
+func (m *modelState) focusTaskField(field string) tea.Cmd {
+	m.taskInput().Blur()
+	m.taskField = field
+	return m.taskInput().Focus()
+}
+
+func (m modelState) resetTaskForm() modelState {
+	m.mode = "tasks"
+	for _, input := range []*textinput.Model{&m.taskNameInput, &m.taskDescInput, &m.taskDueInput} {
+		input.Reset()
+		input.Blur()
+	}
+	m.formError = ""
+	return m
+}
@@ -0,0 +1,107 @@
+// File: tui/week.go
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// showWeek points the strip at the week starting on start, keeping the
+// same weekday selected, and loads it.
+func (m *modelState) showWeek(start time.Time) tea.Cmd {
+	m.today = -1
+	for i := range m.dates {
+		m.dates[i] = start.AddDate(0, 0, i)
+		if m.dates[i].Equal(m.store.Clock().Today()) {
+			m.today = i
+		}
+	}
+	return m.refresh()
+}
+
+// moveDay moves the selected day by step, turning to the neighbouring week
+// past either end of the strip.
+func (m *modelState) moveDay(step int) tea.Cmd {
+	next := m.selected + step
+	if next >= 0 && next < len(m.dates) {
+		m.selected = next
+		return nil
+	}
+	cmd := m.showWeek(m.dates[0].AddDate(0, 0, 7*step))
+	m.selected = (next%len(m.dates) + len(m.dates)) % len(m.dates)
+	return cmd
+}
+
+// dayProgress counts the habits due on date and how many of those are
+// done. Quit habits count as done on clean days, up to today. Habits due a
+// number of times per week or month aren't due on the days they weren't
+// done once their period is met.
+func (m modelState) dayProgress(date time.Time) (done, due int) {
+	dateStr := date.Format("2006-01-02")
+	for _, h := range m.habits {
+		if dateStr < h.CreatedAt {
+			continue
+		}
+		if h.IsQuit() {
+			if date.After(m.store.Clock().Today()) {
+				continue
+			}
+			due++
+			if !m.week.entry(h.ID, date).completed {
+				done++
+			}
+			continue
+		}
+		if !h.Schedule.IsDue(dateStr) {
+			continue
+		}
+		var met bool
+		if h.Goal != nil {
+			met = m.week.goalValue(h, date) >= h.Goal.Target
+		} else {
+			met = m.week.entry(h.ID, date).completed
+		}
+		if first, _, ok := h.QuotaPeriod(dateStr, m.store.Clock().WeekStart); ok && !met && m.week.quotaMet[h.ID][first] {
+			continue
+		}
+		due++
+		if met {
+			done++
+		}
+	}
+	return done, due
+}
+
+func (m modelState) renderWeek() string {
+	cards := make([]string, len(m.dates))
+	for i, date := range m.dates {
+		done, due := m.dayProgress(date)
+		fraction := 0.0
+		if due > 0 {
+			fraction = float64(done) / float64(due)
+		}
+		card := lipgloss.JoinVertical(lipgloss.Center,
+			date.Format("Mon"),
+			date.Format("Jan 2"),
+			"",
+			fmt.Sprintf("%d/%d done", done, due),
+			progressBar(fraction, 6),
+		)
+
+		style := dayStyle
+		switch {
+		case i == m.selected && i == m.today:
+			style = highlightedDay.Inherit(selectedDay)
+		case i == m.selected:
+			style = selectedDay
+		case i == m.today:
+			style = highlightedDay
+		}
+		cards[i] = style.Render(card)
+	}
+	title := "Week of " + m.dates[0].Format(m.cfg.Dates.Day)
+	return lipgloss.JoinVertical(lipgloss.Left, title, lipgloss.JoinHorizontal(lipgloss.Top, cards...))
+}